@@ -2,10 +2,14 @@ package bot
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
+	"io"
 	"io/ioutil"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"time"
 )
 
 type (
@@ -88,6 +92,9 @@ type (
 		Voice *Voice `json:"voice"`
 		// Optional. Caption for the document, photo or video, 0-200 characters.
 		Caption string `json:"caption"`
+		// Optional. For messages with a caption, special entities like
+		// usernames, URLs, bot commands, etc. that appear in the caption.
+		CaptionEntities []MessageEntity `json:"caption_entities"`
 		// Optional. Message is a shared contact, information about the contact.
 		Contact *Contact `json:"contact"`
 		// Optional. Message is a shared location, information about the
@@ -148,6 +155,17 @@ type (
 		// Optional. New version of a message that is known to the bot and was
 		// edited.
 		EditedMessage *Message `json:"edited_message"`
+		// Optional. New incoming channel post of any kind — text, photo,
+		// sticker, etc.
+		ChannelPost *Message `json:"channel_post"`
+		// Optional. New version of a channel post that is known to the bot and
+		// was edited.
+		EditedChannelPost *Message `json:"edited_channel_post"`
+		// Optional. A reaction to a message was changed by a user. The bot must
+		// be an administrator in the chat and must explicitly specify
+		// “message_reaction” in the list of allowed_updates to receive these
+		// updates.
+		MessageReaction *MessageReactionUpdated `json:"message_reaction"`
 		// Optional. New incoming inline query.
 		InlineQuery *InlineQuery `json:"inline_query"`
 		// Optional. The result of an inline query that was chosen by a user and
@@ -155,6 +173,31 @@ type (
 		ChosenInlineResult *ChosenInlineResult `json:"chosen_inline_result"`
 		// Optional. New incoming callback query.
 		CallbackQuery *CallbackQuery `json:"callback_query"`
+		// Optional. New incoming shipping query. Only for invoices with
+		// flexible price.
+		ShippingQuery *ShippingQuery `json:"shipping_query"`
+		// Optional. New incoming pre-checkout query. Contains full information
+		// about checkout.
+		PreCheckoutQuery *PreCheckoutQuery `json:"pre_checkout_query"`
+		// Optional. New poll state. Bots receive only updates about stopped
+		// polls and polls, which are sent by the bot.
+		Poll *Poll `json:"poll"`
+		// Optional. A user changed their answer in a non-anonymous poll. Bots
+		// receive new votes only in polls that were sent by the bot itself.
+		PollAnswer *PollAnswer `json:"poll_answer"`
+		// Optional. The bot‘s chat member status was updated in a chat. For
+		// private chats, this update is received only when the bot is blocked
+		// or unblocked by the user.
+		MyChatMember *ChatMemberUpdated `json:"my_chat_member"`
+		// Optional. A chat member‘s status was updated in a chat. The bot must
+		// be an administrator in the chat and must explicitly specify
+		// “chat_member” in the list of allowed_updates to receive these
+		// updates.
+		ChatMember *ChatMemberUpdated `json:"chat_member"`
+		// Optional. A request to join the chat has been sent. The bot must have
+		// the can_invite_users administrator right in the chat to receive these
+		// updates.
+		ChatJoinRequest *ChatJoinRequest `json:"chat_join_request"`
 	}
 
 	// MessageEntity represents one special entity in a text message. For
@@ -171,9 +214,12 @@ type (
 		Length int `json:"length"`
 		// Optional. For “text_link” only, url that will be opened after user
 		// taps on the text.
-		URL string `json:"url"`
+		URL string `json:"url,omitempty"`
 		// Optional. For “text_mention” only, the mentioned user.
-		User *User `json:"user"`
+		User *User `json:"user,omitempty"`
+		// Optional. For “pre” only, the programming language of the entity
+		// text.
+		Language string `json:"language,omitempty"`
 	}
 
 	Game struct {
@@ -393,6 +439,274 @@ type (
 		GameShortName string `json:"game_short_name"`
 	}
 
+	// ShippingAddress represents a shipping address.
+	ShippingAddress struct {
+		// Two-letter ISO 3166-1 alpha-2 country code.
+		CountryCode string `json:"country_code"`
+		// State, if applicable.
+		State string `json:"state"`
+		// City.
+		City string `json:"city"`
+		// First line for the address.
+		StreetLine1 string `json:"street_line1"`
+		// Second line for the address.
+		StreetLine2 string `json:"street_line2"`
+		// Address post code.
+		PostCode string `json:"post_code"`
+	}
+
+	// OrderInfo represents information about an order.
+	OrderInfo struct {
+		// Optional. User name.
+		Name string `json:"name"`
+		// Optional. User's phone number.
+		PhoneNumber string `json:"phone_number"`
+		// Optional. User email.
+		Email string `json:"email"`
+		// Optional. User shipping address.
+		ShippingAddress *ShippingAddress `json:"shipping_address"`
+	}
+
+	// ShippingQuery contains information about an incoming shipping query.
+	ShippingQuery struct {
+		// Unique query identifier.
+		ID string `json:"id"`
+		// User who sent the query.
+		From *User `json:"from"`
+		// Bot specified invoice payload.
+		InvoicePayload string `json:"invoice_payload"`
+		// User specified shipping address.
+		ShippingAddress *ShippingAddress `json:"shipping_address"`
+	}
+
+	// PreCheckoutQuery contains information about an incoming pre-checkout
+	// query.
+	PreCheckoutQuery struct {
+		// Unique query identifier.
+		ID string `json:"id"`
+		// User who sent the query.
+		From *User `json:"from"`
+		// Three-letter ISO 4217 currency code.
+		Currency string `json:"currency"`
+		// Total price in the smallest units of the currency (integer, not
+		// float/double). For example, for a price of US$ 1.45 pass amount =
+		// 145.
+		TotalAmount int `json:"total_amount"`
+		// Bot specified invoice payload.
+		InvoicePayload string `json:"invoice_payload"`
+		// Optional. Identifier of the shipping option chosen by the user.
+		ShippingOptionID string `json:"shipping_option_id"`
+		// Optional. Order information provided by the user.
+		OrderInfo *OrderInfo `json:"order_info"`
+	}
+
+	// PollOption contains information about one answer option in a poll.
+	PollOption struct {
+		// Option text, 1-100 characters.
+		Text string `json:"text"`
+		// Number of users that voted for this option.
+		VoterCount int `json:"voter_count"`
+	}
+
+	// Poll contains information about a poll.
+	Poll struct {
+		// Unique poll identifier.
+		ID string `json:"id"`
+		// Poll question, 1-300 characters.
+		Question string `json:"question"`
+		// List of poll options.
+		Options []PollOption `json:"options"`
+		// Total number of users that voted in the poll.
+		TotalVoterCount int `json:"total_voter_count"`
+		// True, if the poll is closed.
+		IsClosed bool `json:"is_closed"`
+		// True, if the poll is anonymous.
+		IsAnonymous bool `json:"is_anonymous"`
+		// Poll type, currently can be “regular” or “quiz”.
+		Type string `json:"type"`
+		// True, if the poll allows multiple answers.
+		AllowsMultipleAnswers bool `json:"allows_multiple_answers"`
+		// Optional. 0-based identifier of the correct answer option. Available
+		// only for polls in the quiz mode, which are closed, or was sent (not
+		// forwarded) by the bot or to the private chat with the bot.
+		CorrectOptionID *int `json:"correct_option_id"`
+		// Optional. Text that is shown when a user chooses an incorrect answer
+		// or taps on the lamp icon in a quiz-style poll, 0-200 characters.
+		Explanation string `json:"explanation"`
+		// Optional. Amount of time in seconds the poll will be active after
+		// creation.
+		OpenPeriod int `json:"open_period"`
+		// Optional. Point in time (Unix timestamp) when the poll will be
+		// automatically closed.
+		CloseDate uint64 `json:"close_date"`
+	}
+
+	// PollAnswer represents an answer of a user in a non-anonymous poll.
+	PollAnswer struct {
+		// Unique poll identifier.
+		PollID string `json:"poll_id"`
+		// Optional. The chat that changed the answer to the poll, if the voter
+		// is anonymous.
+		VoterChat *Chat `json:"voter_chat"`
+		// Optional. The user that changed the answer to the poll, if the voter
+		// isn‘t anonymous.
+		User *User `json:"user"`
+		// 0-based identifiers of chosen answer options. May be empty if the
+		// vote was retracted.
+		OptionIDs []int `json:"option_ids"`
+	}
+
+	// ChatMember contains information about one member of a chat. Only the
+	// fields relevant to the Status are present.
+	ChatMember struct {
+		// The member‘s status in the chat. Can be “creator”, “administrator”,
+		// “member”, “restricted”, “left” or “kicked”.
+		Status string `json:"status"`
+		// Information about the user.
+		User *User `json:"user"`
+		// Optional. Custom title for owners and administrators.
+		CustomTitle string `json:"custom_title"`
+		// Optional. True, if the user‘s presence in the chat is hidden.
+		IsAnonymous bool `json:"is_anonymous"`
+		// Optional. Restricted and kicked only. Date when restrictions will be
+		// lifted for this user; Unix time. If 0, then the user is restricted
+		// forever.
+		UntilDate uint64 `json:"until_date"`
+		// Optional. Restricted only. True, if the user is a member of the chat
+		// at the moment of the request.
+		IsMember bool `json:"is_member"`
+		// Optional. Administrators only. True, if the bot is allowed to edit
+		// administrator privileges of that user.
+		CanBeEdited bool `json:"can_be_edited"`
+		// Optional. Administrators only. True, if the administrator can access
+		// the chat event log, get boost list, see hidden supergroup and channel
+		// members, report spam messages and ignore slow mode.
+		CanManageChat bool `json:"can_manage_chat"`
+		// Optional. Administrators only. True, if the administrator can delete
+		// messages of other users.
+		CanDeleteMessages bool `json:"can_delete_messages"`
+		// Optional. Administrators only. True, if the administrator can
+		// restrict, ban or unban chat members.
+		CanRestrictMembers bool `json:"can_restrict_members"`
+		// Optional. Administrators only. True, if the administrator can add new
+		// administrators.
+		CanPromoteMembers bool `json:"can_promote_members"`
+		// Optional. True, if the user is allowed to change the chat title,
+		// photo and other settings.
+		CanChangeInfo bool `json:"can_change_info"`
+		// Optional. True, if the user is allowed to invite new users to the
+		// chat.
+		CanInviteUsers bool `json:"can_invite_users"`
+		// Optional. True, if the user is allowed to pin messages.
+		CanPinMessages bool `json:"can_pin_messages"`
+		// Optional. Restricted only. True, if the user is allowed to send text
+		// messages, contacts, locations and venues.
+		CanSendMessages bool `json:"can_send_messages"`
+	}
+
+	// ChatInviteLink represents an invite link for a chat.
+	ChatInviteLink struct {
+		// The invite link. If the link was created by another chat
+		// administrator, then the second part of the link will be replaced
+		// with “…”.
+		InviteLink string `json:"invite_link"`
+		// Creator of the link.
+		Creator *User `json:"creator"`
+		// True, if users joining the chat via the link need to be approved by
+		// chat administrators.
+		CreatesJoinRequest bool `json:"creates_join_request"`
+		// True, if the link is primary.
+		IsPrimary bool `json:"is_primary"`
+		// True, if the link is revoked.
+		IsRevoked bool `json:"is_revoked"`
+		// Optional. Invite link name.
+		Name string `json:"name"`
+		// Optional. Point in time (Unix timestamp) when the link will expire or
+		// has been expired.
+		ExpireDate uint64 `json:"expire_date"`
+		// Optional. The maximum number of users that can be members of the chat
+		// simultaneously after joining the chat via this invite link;
+		// 1-99999.
+		MemberLimit int `json:"member_limit"`
+		// Optional. Number of pending join requests created using this link.
+		PendingJoinRequestCount int `json:"pending_join_request_count"`
+	}
+
+	// ChatMemberUpdated represents changes in the status of a chat member.
+	ChatMemberUpdated struct {
+		// Chat the user belongs to.
+		Chat *Chat `json:"chat"`
+		// Performer of the action, which resulted in the change.
+		From *User `json:"from"`
+		// Date the change was done in Unix time.
+		Date uint64 `json:"date"`
+		// Previous information about the chat member.
+		OldChatMember *ChatMember `json:"old_chat_member"`
+		// New information about the chat member.
+		NewChatMember *ChatMember `json:"new_chat_member"`
+		// Optional. Chat invite link, which was used by the user to join the
+		// chat; for joining by invite link events only.
+		InviteLink *ChatInviteLink `json:"invite_link"`
+		// Optional. True, if the user joined the chat after sending a direct
+		// join request without using an invite link and being approved by an
+		// administrator.
+		ViaJoinRequest bool `json:"via_join_request"`
+		// Optional. True, if the user joined the chat via a chat folder invite
+		// link.
+		ViaChatFolderInviteLink bool `json:"via_chat_folder_invite_link"`
+	}
+
+	// ChatJoinRequest represents a join request sent to a chat.
+	ChatJoinRequest struct {
+		// Chat to which the request was sent.
+		Chat *Chat `json:"chat"`
+		// User that sent the join request.
+		From *User `json:"from"`
+		// Identifier of a private chat with the user who sent the join request.
+		// The bot can use this identifier for 5 minutes to send messages until
+		// the join request is processed, assuming no other administrator
+		// contacted the user.
+		UserChatID int64 `json:"user_chat_id"`
+		// Date the request was sent in Unix time.
+		Date uint64 `json:"date"`
+		// Optional. Bio of the user.
+		Bio string `json:"bio"`
+		// Optional. Chat invite link that was used by the user to send the join
+		// request.
+		InviteLink *ChatInviteLink `json:"invite_link"`
+	}
+
+	// ReactionType describes the type of a reaction.
+	ReactionType struct {
+		// Type of the reaction, can be “emoji”, “custom_emoji” or “paid”.
+		Type string `json:"type"`
+		// Optional. For “emoji” only, the reaction emoji.
+		Emoji string `json:"emoji,omitempty"`
+		// Optional. For “custom_emoji” only, custom emoji identifier.
+		CustomEmojiID string `json:"custom_emoji_id,omitempty"`
+	}
+
+	// MessageReactionUpdated represents a change of a reaction on a message
+	// performed by a user.
+	MessageReactionUpdated struct {
+		// The chat containing the message the user reacted to.
+		Chat *Chat `json:"chat"`
+		// Unique identifier of the message inside the chat.
+		MessageID int `json:"message_id"`
+		// Optional. The user that changed the reaction, if the user isn‘t
+		// anonymous.
+		User *User `json:"user"`
+		// Optional. The chat on behalf of which the reaction was changed, if
+		// the user is anonymous.
+		ActorChat *Chat `json:"actor_chat"`
+		// Date of the change in Unix time.
+		Date uint64 `json:"date"`
+		// Previous list of reaction types that were set by the user.
+		OldReaction []ReactionType `json:"old_reaction"`
+		// New list of reaction types that have been set by the user.
+		NewReaction []ReactionType `json:"new_reaction"`
+	}
+
 	// KeyboardButton represents one button of the reply keyboard. For simple
 	// text buttons String can be used instead of this object to specify text of
 	// the button.
@@ -424,6 +738,26 @@ type (
 		// reply_to_message_id), sender of the original message.
 		Selective bool `json:"selective"`
 	}
+
+	// InlineKeyboardButton represents one button of an inline keyboard. You
+	// must use exactly one of the optional fields.
+	InlineKeyboardButton struct {
+		// Label text on the button.
+		Text string `json:"text"`
+		// Optional. HTTP url to be opened when button is pressed.
+		URL string `json:"url,omitempty"`
+		// Optional. Data to be sent in a callback query to the bot when button
+		// is pressed, 1-64 bytes. See CallbackCodec.
+		CallbackData string `json:"callback_data,omitempty"`
+	}
+
+	// InlineKeyboardMarkup represents an inline keyboard that appears right
+	// next to the message it belongs to.
+	InlineKeyboardMarkup struct {
+		// Array of button rows, each represented by an Array of
+		// InlineKeyboardButton objects.
+		InlineKeyboard [][]*InlineKeyboardButton `json:"inline_keyboard"`
+	}
 )
 
 // Request and response wrappers are defined here.
@@ -437,6 +771,30 @@ type (
 		// false and the error is explained in the ‘description’.
 		OK          bool   `json:"ok"`
 		Description string `json:"description"`
+		// An Integer ‘error_code’ field is also returned, but its contents are
+		// subject to change in the future.
+		ErrorCode int `json:"error_code,omitempty"`
+		// Some errors may also have an optional field ‘parameters’, which can
+		// help to automatically handle the error.
+		Parameters *ResponseParameters `json:"parameters,omitempty"`
+	}
+
+	// Contains information about why a request was unsuccessful.
+	ResponseParameters struct {
+		// Optional. The group has been migrated to a supergroup with the
+		// specified identifier.
+		MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"`
+		// Optional. In case of exceeding flood control, the number of seconds
+		// left to wait before the request can be repeated.
+		RetryAfter int `json:"retry_after,omitempty"`
+	}
+
+	// APIError is returned when the Bot API answers a request with ‘ok’ equal
+	// to false. Its message is the description of the response.
+	APIError struct {
+		Code        int
+		Description string
+		Parameters  *ResponseParameters
 	}
 
 	GetMeResponse struct {
@@ -444,11 +802,105 @@ type (
 		Result *User `json:"result"`
 	}
 
+	GetUpdatesRequest struct {
+		// Identifier of the first update to be returned. Must be greater by
+		// one than the highest among the identifiers of previously received
+		// updates. By default, updates starting with the earliest unconfirmed
+		// update are returned.
+		Offset int `json:"offset"`
+		// Limits the number of updates to be retrieved. Values between 1—100
+		// are accepted. Defaults to 100.
+		Limit int `json:"limit,omitempty"`
+		// Timeout in seconds for long polling. Defaults to 0, i.e. usual short
+		// polling.
+		Timeout int `json:"timeout"`
+		// List the types of updates you want your bot to receive. For example,
+		// specify [“message”, “callback_query”] to only receive updates of
+		// these types. If not specified, the previous setting will be used.
+		// Always sent: defaults to the allowed updates of the bot, see
+		// AllowedUpdates, and an empty list asks for all types except
+		// chat_member and message_reaction.
+		AllowedUpdates []string `json:"allowed_updates"`
+	}
+
 	GetUpdatesResponse struct {
 		Response
 		Result []Update `json:"result"`
 	}
 
+	SetWebhookRequest struct {
+		// HTTPS URL to send updates to. Use an empty string to remove webhook
+		// integration.
+		URL string `json:"url"`
+		// Optional. Upload your public key certificate so that the root
+		// certificate in use can be checked.
+		Certificate *InputFile `json:"-"`
+		// Optional. The fixed IP address which will be used to send webhook
+		// requests instead of the IP address resolved through DNS.
+		IPAddress string `json:"ip_address,omitempty"`
+		// Optional. The maximum allowed number of simultaneous HTTPS
+		// connections to the webhook for update delivery, 1-100. Defaults to
+		// 40.
+		MaxConnections int `json:"max_connections,omitempty"`
+		// Optional. List of the update types you want your bot to receive.
+		// Defaults to the allowed updates of the bot, see AllowedUpdates.
+		AllowedUpdates []string `json:"allowed_updates"`
+		// Optional. Pass true to drop all pending updates.
+		DropPendingUpdates bool `json:"drop_pending_updates,omitempty"`
+		// Optional. A secret token to be sent in a header
+		// “X-Telegram-Bot-Api-Secret-Token” in every webhook request, 1-256
+		// characters. Only characters A-Z, a-z, 0-9, _ and - are allowed.
+		SecretToken string `json:"secret_token,omitempty"`
+	}
+
+	SetWebhookResponse struct {
+		Response
+		Result bool `json:"result"`
+	}
+
+	DeleteWebhookRequest struct {
+		// Optional. Pass true to drop all pending updates.
+		DropPendingUpdates bool `json:"drop_pending_updates,omitempty"`
+	}
+
+	DeleteWebhookResponse struct {
+		Response
+		Result bool `json:"result"`
+	}
+
+	// WebhookInfo describes the current status of a webhook.
+	WebhookInfo struct {
+		// Webhook URL, may be empty if webhook is not set up.
+		URL string `json:"url"`
+		// True, if a custom certificate was provided for webhook certificate
+		// checks.
+		HasCustomCertificate bool `json:"has_custom_certificate"`
+		// Number of updates awaiting delivery.
+		PendingUpdateCount int `json:"pending_update_count"`
+		// Optional. Currently used webhook IP address.
+		IPAddress string `json:"ip_address"`
+		// Optional. Unix time for the most recent error that happened when
+		// trying to deliver an update via webhook.
+		LastErrorDate uint64 `json:"last_error_date"`
+		// Optional. Error message in human-readable format for the most recent
+		// error that happened when trying to deliver an update via webhook.
+		LastErrorMessage string `json:"last_error_message"`
+		// Optional. Unix time of the most recent error that happened when
+		// trying to synchronize available updates with Telegram datacenters.
+		LastSynchronizationErrorDate uint64 `json:"last_synchronization_error_date"`
+		// Optional. The maximum allowed number of simultaneous HTTPS
+		// connections to the webhook for update delivery.
+		MaxConnections int `json:"max_connections"`
+		// Optional. A list of update types the bot is subscribed to. Defaults
+		// to all update types except chat_member.
+		AllowedUpdates []string `json:"allowed_updates"`
+	}
+
+	GetWebhookInfoResponse struct {
+		Response
+		Result *WebhookInfo `json:"result"`
+	}
+
 	SendMessageRequest struct {
 		// Unique identifier for the target chat or username of the target
 		// channel (in the format @channelusername).
@@ -456,8 +908,12 @@ type (
 		// Text of the message to be sent.
 		Text string `json:"text"`
 		// Send Markdown or HTML, if you want Telegram apps to show bold,
-		// italic, fixed-width text or inline URLs in your bot's message.
+		// italic, fixed-width text or inline URLs in your bot's message. The
+		// format package builds texts escaped for HTML and MarkdownV2.
 		ParseMode string `json:"parse_mode"`
+		// List of special entities that appear in message text, which can be
+		// specified instead of parse_mode. See TextBuilder.
+		Entities []MessageEntity `json:"entities,omitempty"`
 		// Disables link previews for links in this message.
 		DisableWebPagePreview bool `json:"disable_web_page_preview"`
 		// Sends the message silently.
@@ -468,9 +924,10 @@ type (
 		ReplyToMessageID int `json:"reply_to_message_id"`
 		// Additional interface options. A JSON-serialized object for an inline
 		// keyboard, custom reply keyboard, instructions to hide reply keyboard
-		// or to force a reply from the user.
-		// TODO: Support InlineKeyboardMarkup, ReplyKeyboardHide and ForceReply.
-		ReplyMarkup *ReplyKeyboardMarkup `json:"reply_markup,omitempty"`
+		// or to force a reply from the user. Either an *InlineKeyboardMarkup
+		// or a *ReplyKeyboardMarkup.
+		// TODO: Support ReplyKeyboardHide and ForceReply.
+		ReplyMarkup interface{} `json:"reply_markup,omitempty"`
 	}
 
 	SendMessageResponse struct {
@@ -523,27 +980,268 @@ type (
 		Response
 		Result *Message `json:"result"`
 	}
+
+	// InputFile is a file to upload using multipart/form-data.
+	InputFile struct {
+		// Name of the file.
+		Name string
+		// Content of the file.
+		Reader io.Reader
+	}
+
+	SendDocumentRequest struct {
+		// Unique identifier for the target chat or username of the target
+		// channel (in the format @channelusername).
+		ChatID int64 `json:"chat_id"`
+		// File to send. Pass a file_id as String to send a file that exists on
+		// the Telegram servers (recommended) or pass an HTTP URL as a String
+		// for Telegram to get a file from the Internet.
+		Document string `json:"document,omitempty"`
+		// File to upload, instead of Document.
+		DocumentFile *InputFile `json:"-"`
+		// Document caption, 0-1024 characters.
+		Caption string `json:"caption,omitempty"`
+		// Send Markdown or HTML, if you want Telegram apps to show bold,
+		// italic, fixed-width text or inline URLs in the media caption.
+		ParseMode string `json:"parse_mode,omitempty"`
+		// List of special entities that appear in the caption, which can be
+		// specified instead of parse_mode.
+		CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+		// Sends the message silently.
+		// iOS users will not receive a notification, Android users will receive
+		// a notification with no sound.
+		DisableNotification bool `json:"disable_notification"`
+		// If the message is a reply, ID of the original message.
+		ReplyToMessageID int `json:"reply_to_message_id"`
+		// Additional interface options. Either an *InlineKeyboardMarkup or a
+		// *ReplyKeyboardMarkup.
+		ReplyMarkup interface{} `json:"reply_markup,omitempty"`
+	}
+
+	SendDocumentResponse struct {
+		Response
+		Result *Message `json:"result"`
+	}
+
+	AnswerCallbackQueryRequest struct {
+		// Unique identifier for the query to be answered.
+		CallbackQueryID string `json:"callback_query_id"`
+		// Optional. Text of the notification. If not specified, nothing will be
+		// shown to the user, 0-200 characters.
+		Text string `json:"text,omitempty"`
+		// Optional. If true, an alert will be shown by the client instead of a
+		// notification at the top of the chat screen. Defaults to false.
+		ShowAlert bool `json:"show_alert,omitempty"`
+		// Optional. URL that will be opened by the user's client. It may be a
+		// game's URL or a t.me link that opens the bot with a parameter.
+		URL string `json:"url,omitempty"`
+		// Optional. The maximum amount of time in seconds that the result of
+		// the callback query may be cached client-side. Defaults to 0.
+		CacheTime int `json:"cache_time,omitempty"`
+	}
+
+	AnswerCallbackQueryResponse struct {
+		Response
+		Result bool `json:"result"`
+	}
+
+	EditMessageTextRequest struct {
+		// Required if inline_message_id is not specified. Unique identifier for
+		// the target chat or username of the target channel (in the format
+		// @channelusername).
+		ChatID int64 `json:"chat_id,omitempty"`
+		// Required if inline_message_id is not specified. Unique identifier of
+		// the sent message.
+		MessageID int `json:"message_id,omitempty"`
+		// Required if chat_id and message_id are not specified. Identifier of
+		// the inline message.
+		InlineMessageID string `json:"inline_message_id,omitempty"`
+		// New text of the message.
+		Text string `json:"text"`
+		// Send Markdown or HTML, if you want Telegram apps to show bold,
+		// italic, fixed-width text or inline URLs in your bot's message.
+		ParseMode string `json:"parse_mode"`
+		// List of special entities that appear in message text, which can be
+		// specified instead of parse_mode.
+		Entities []MessageEntity `json:"entities,omitempty"`
+		// Disables link previews for links in this message.
+		DisableWebPagePreview bool `json:"disable_web_page_preview"`
+		// A JSON-serialized object for an inline keyboard.
+		ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	}
+
+	EditMessageCaptionRequest struct {
+		// Required if inline_message_id is not specified. Unique identifier for
+		// the target chat or username of the target channel (in the format
+		// @channelusername).
+		ChatID int64 `json:"chat_id,omitempty"`
+		// Required if inline_message_id is not specified. Unique identifier of
+		// the sent message.
+		MessageID int `json:"message_id,omitempty"`
+		// Required if chat_id and message_id are not specified. Identifier of
+		// the inline message.
+		InlineMessageID string `json:"inline_message_id,omitempty"`
+		// New caption of the message.
+		Caption string `json:"caption"`
+		// Send Markdown or HTML, if you want Telegram apps to show bold,
+		// italic, fixed-width text or inline URLs in the media caption.
+		ParseMode string `json:"parse_mode"`
+		// List of special entities that appear in the caption, which can be
+		// specified instead of parse_mode.
+		CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+		// A JSON-serialized object for an inline keyboard.
+		ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	}
+
+	EditMessageReplyMarkupRequest struct {
+		// Required if inline_message_id is not specified. Unique identifier for
+		// the target chat or username of the target channel (in the format
+		// @channelusername).
+		ChatID int64 `json:"chat_id,omitempty"`
+		// Required if inline_message_id is not specified. Unique identifier of
+		// the sent message.
+		MessageID int `json:"message_id,omitempty"`
+		// Required if chat_id and message_id are not specified. Identifier of
+		// the inline message.
+		InlineMessageID string `json:"inline_message_id,omitempty"`
+		// A JSON-serialized object for an inline keyboard.
+		ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	}
+
+	// EditMessageResponse is the response of the methods editing messages.
+	// The result is the edited Message, or True if the message was sent via
+	// the bot (in inline mode).
+	EditMessageResponse struct {
+		Response
+		Result json.RawMessage `json:"result"`
+	}
 )
 
 // Call Telegram API method.
 func (e *Bot) CallMethod(method string, params interface{}) ([]byte, error) {
-	url := "https://api.telegram.org/bot" + e.token + "/" + method
 	body, err := json.Marshal(params)
 	if err != nil {
 		return nil, err
 	}
-	res, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	ctx, span := e.startCallSpan(method)
+	start := time.Now()
+	result, err := e.post(ctx, method, "application/json", bytes.NewBuffer(body))
+	e.observeCall(method, start, result, err, span)
+	if method != "getUpdates" {
+		e.recordCall(method, body, result, err)
+	}
+	return result, err
+}
+
+// Call Telegram API method, uploading files using multipart/form-data. The
+// params are sent as form fields: strings as is, other values as JSON.
+func (e *Bot) CallMethodWithFiles(method string, params interface{}, files map[string]*InputFile) ([]byte, error) {
+	body, err := json.Marshal(params)
 	if err != nil {
 		return nil, err
 	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	r, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		w.CloseWithError(writeForm(form, fields, files))
+	}()
+	ctx, span := e.startCallSpan(method)
+	start := time.Now()
+	result, err := e.post(ctx, method, form.FormDataContentType(), r)
+	r.Close()
+	e.observeCall(method, start, result, err, span)
+	e.recordCall(method, body, result, err)
+	return result, err
+}
+
+func writeForm(form *multipart.Writer, fields map[string]json.RawMessage, files map[string]*InputFile) error {
+	for name, value := range fields {
+		var s string
+		if json.Unmarshal(value, &s) != nil {
+			s = string(value)
+		}
+		if err := form.WriteField(name, s); err != nil {
+			return err
+		}
+	}
+	for name, file := range files {
+		part, err := form.CreateFormFile(name, file.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, file.Reader); err != nil {
+			return err
+		}
+	}
+	return form.Close()
+}
+
+// Log a call to the Bot API at the debug level, report it to the metrics and
+// end its span. The response is only decoded if any of them needs it.
+func (e *Bot) observeCall(method string, start time.Time, result []byte, err error, span Span) {
+	logger := e.Logger()
+	debug := logger.Enabled(context.Background(), slog.LevelDebug)
+	if !debug && e.metrics == nil && span == nil {
+		return
+	}
+	duration := time.Since(start)
+	status := &Response{}
+	if err == nil && json.Unmarshal(result, status) == nil && !status.OK {
+		err = status.apiError()
+	}
+	endSpan(span, err)
+	if e.metrics != nil {
+		e.metrics.ObserveCall(method, duration, err)
+	}
+	if debug {
+		args := []interface{}{
+			slog.String("method", method),
+			slog.Duration("duration", duration),
+		}
+		if err != nil {
+			args = append(args, errorAttrs(err)...)
+		}
+		logger.Debug("api call", args...)
+	}
+}
+
+// Post a request to the Bot API. Errors never contain the token of the bot.
+func (e *Bot) post(ctx context.Context, method, contentType string, body io.Reader) ([]byte, error) {
+	url := e.apiURL + "/bot" + e.token + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, e.redact(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, e.redact(err)
+	}
 	result, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
 	if err != nil {
-		return nil, err
+		return nil, e.redact(err)
 	}
 	return result, nil
 }
 
+func (e *APIError) Error() string {
+	return e.Description
+}
+
+// Error of an unsuccessful response.
+func (r *Response) apiError() error {
+	return &APIError{
+		Code:        r.ErrorCode,
+		Description: r.Description,
+		Parameters:  r.Parameters,
+	}
+}
+
 // A simple method for testing your bot's auth token. Requires no parameters.
 // Returns basic information about the bot in form of a User object.
 func (e *Bot) GetMe() (*User, error) {
@@ -557,20 +1255,20 @@ func (e *Bot) GetMe() (*User, error) {
 		return nil, err
 	}
 	if !me.OK {
-		return nil, errors.New(me.Description)
+		return nil, me.apiError()
 	}
 	return me.Result, nil
 }
 
 // Receive incoming updates using long polling (wiki). An Array of Update
 // objects is returned.
-func (e *Bot) GetUpdates(offset, limit, timeout int) ([]Update, error) {
-	res, err := e.CallMethod("getUpdates", map[string]int{
-		"offset":  offset,
-		"limit":   limit,
-		"timeout": timeout,
-	})
-
+func (e *Bot) GetUpdates(body *GetUpdatesRequest) ([]Update, error) {
+	if body.AllowedUpdates == nil {
+		params := *body
+		params.AllowedUpdates = e.AllowedUpdates()
+		body = &params
+	}
+	res, err := e.CallMethod("getUpdates", body)
 	if err != nil {
 		return nil, err
 	}
@@ -580,11 +1278,77 @@ func (e *Bot) GetUpdates(offset, limit, timeout int) ([]Update, error) {
 		return nil, err
 	}
 	if !updates.OK {
-		return nil, errors.New(updates.Description)
+		return nil, updates.apiError()
 	}
 	return updates.Result, nil
 }
 
+// Specify a url and receive incoming updates via an outgoing webhook. Unless
+// set in the request, the allowed updates are the ones of the bot.
+func (e *Bot) SetWebhook(body *SetWebhookRequest) error {
+	if body.AllowedUpdates == nil {
+		params := *body
+		params.AllowedUpdates = e.AllowedUpdates()
+		body = &params
+	}
+	var res []byte
+	var err error
+	if body.Certificate != nil {
+		res, err = e.CallMethodWithFiles("setWebhook", body, map[string]*InputFile{
+			"certificate": body.Certificate,
+		})
+	} else {
+		res, err = e.CallMethod("setWebhook", body)
+	}
+	if err != nil {
+		return err
+	}
+	result := &SetWebhookResponse{}
+	err = json.Unmarshal(res, result)
+	if err != nil {
+		return err
+	}
+	if !result.OK {
+		return result.apiError()
+	}
+	return nil
+}
+
+// Remove webhook integration if you decide to switch back to getUpdates.
+func (e *Bot) DeleteWebhook(body *DeleteWebhookRequest) error {
+	res, err := e.CallMethod("deleteWebhook", body)
+	if err != nil {
+		return err
+	}
+	result := &DeleteWebhookResponse{}
+	err = json.Unmarshal(res, result)
+	if err != nil {
+		return err
+	}
+	if !result.OK {
+		return result.apiError()
+	}
+	return nil
+}
+
+// Get current webhook status. If the bot is using getUpdates, will return an
+// object with the url field empty.
+func (e *Bot) GetWebhookInfo() (*WebhookInfo, error) {
+	res, err := e.CallMethod("getWebhookInfo", nil)
+	if err != nil {
+		return nil, err
+	}
+	info := &GetWebhookInfoResponse{}
+	err = json.Unmarshal(res, info)
+	if err != nil {
+		return nil, err
+	}
+	if !info.OK {
+		return nil, info.apiError()
+	}
+	return info.Result, nil
+}
+
 // Send text messages. On success, the sent Message is returned.
 func (e *Bot) SendMessage(body *SendMessageRequest) (*Message, error) {
 	res, err := e.CallMethod("sendMessage", body)
@@ -597,7 +1361,7 @@ func (e *Bot) SendMessage(body *SendMessageRequest) (*Message, error) {
 		return nil, err
 	}
 	if !message.OK {
-		return nil, errors.New(message.Description)
+		return nil, message.apiError()
 	}
 	return message.Result, nil
 }
@@ -614,7 +1378,7 @@ func (e *Bot) ForwardMessage(body *ForwardMessageRequest) (*Message, error) {
 		return nil, err
 	}
 	if !message.OK {
-		return nil, errors.New(message.Description)
+		return nil, message.apiError()
 	}
 	return message.Result, nil
 }
@@ -623,7 +1387,30 @@ func (e *Bot) ForwardMessage(body *ForwardMessageRequest) (*Message, error) {
 
 // TODO: sendAudio
 
-// TODO: sendDocument
+// Send general files. On success, the sent Message is returned.
+func (e *Bot) SendDocument(body *SendDocumentRequest) (*Message, error) {
+	var res []byte
+	var err error
+	if body.DocumentFile != nil {
+		res, err = e.CallMethodWithFiles("sendDocument", body, map[string]*InputFile{
+			"document": body.DocumentFile,
+		})
+	} else {
+		res, err = e.CallMethod("sendDocument", body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	message := &SendDocumentResponse{}
+	err = json.Unmarshal(res, message)
+	if err != nil {
+		return nil, err
+	}
+	if !message.OK {
+		return nil, message.apiError()
+	}
+	return message.Result, nil
+}
 
 // Send .webp stickers. On success, the sent Message is returned.
 func (e *Bot) SendSticker(body *SendStickerRequest) (*Message, error) {
@@ -637,7 +1424,7 @@ func (e *Bot) SendSticker(body *SendStickerRequest) (*Message, error) {
 		return nil, err
 	}
 	if !message.OK {
-		return nil, errors.New(message.Description)
+		return nil, message.apiError()
 	}
 	return message.Result, nil
 }
@@ -672,13 +1459,69 @@ func (e *Bot) SendSticker(body *SendStickerRequest) (*Message, error) {
 
 // TODO: getChatMember
 
-// TODO: answerCallbackQuery
+// Send answers to callback queries sent from inline keyboards. The answer
+// will be displayed to the user as a notification at the top of the chat
+// screen or as an alert.
+func (e *Bot) AnswerCallbackQuery(body *AnswerCallbackQueryRequest) error {
+	res, err := e.CallMethod("answerCallbackQuery", body)
+	if err != nil {
+		return err
+	}
+	answer := &AnswerCallbackQueryResponse{}
+	err = json.Unmarshal(res, answer)
+	if err != nil {
+		return err
+	}
+	if !answer.OK {
+		return answer.apiError()
+	}
+	return nil
+}
 
-// TODO: editMessageText
+// Edit text messages sent by the bot or via the bot (for inline bots). On
+// success, if edited message is sent by the bot, the edited Message is
+// returned, otherwise nil is returned.
+func (e *Bot) EditMessageText(body *EditMessageTextRequest) (*Message, error) {
+	return e.editMessage("editMessageText", body)
+}
+
+func (e *Bot) editMessage(method string, body interface{}) (*Message, error) {
+	res, err := e.CallMethod(method, body)
+	if err != nil {
+		return nil, err
+	}
+	edited := &EditMessageResponse{}
+	err = json.Unmarshal(res, edited)
+	if err != nil {
+		return nil, err
+	}
+	if !edited.OK {
+		return nil, edited.apiError()
+	}
+	if len(edited.Result) == 0 || edited.Result[0] != '{' {
+		return nil, nil
+	}
+	message := &Message{}
+	err = json.Unmarshal(edited.Result, message)
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
 
-// TODO: editMessageCaption
+// Edit captions of messages sent by the bot or via the bot (for inline bots).
+// On success, if edited message is sent by the bot, the edited Message is
+// returned, otherwise nil is returned.
+func (e *Bot) EditMessageCaption(body *EditMessageCaptionRequest) (*Message, error) {
+	return e.editMessage("editMessageCaption", body)
+}
 
-// TODO: editMessageReplyMarkup
+// Edit only the reply markup of messages sent by the bot or via the bot (for
+// inline bots). On success, if edited message is sent by the bot, the edited
+// Message is returned, otherwise nil is returned.
+func (e *Bot) EditMessageReplyMarkup(body *EditMessageReplyMarkupRequest) (*Message, error) {
+	return e.editMessage("editMessageReplyMarkup", body)
+}
 
 // TODO: answerInlineQuery
 
@@ -1,65 +1,247 @@
 package bot
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
 	"time"
 )
 
 type (
 	// Top-level framework instance.
 	Bot struct {
-		token    string
-		handlers []HandlerFunc
+		token       string
+		apiURL      string
+		client      *http.Client
+		handlers    []HandlerFunc
+		onPanic     PanicHandler
+		workers     int
+		queueSize   int
+		offsetStore OffsetStore
+		// Update kinds handled by the router and whether there are handlers
+		// taking every kind of update.
+		routes   map[string]bool
+		catchAll bool
+		// Explicitly requested update kinds, overriding the routes.
+		allowedUpdates []string
+		sessionStore   SessionStore
+		sessionKey     SessionKeyFunc
+		recorder       *Recorder
+		deleteWebhook  bool
+		webhook        *WebhookConfig
+		logger         *slog.Logger
+		metrics        Metrics
+		tracer         Tracer
+		// Context of the calls to the Bot API, see WithContext.
+		ctx context.Context
 	}
 
 	// Bot running mode.
 	Mode int
 
-	// HandlerFunc defines a function to resolve updates. Returns true or an
-	// error will terminate the handlers chain.
-	HandlerFunc func(*Bot, *Update) error
+	// HandlerFunc defines a function to resolve updates. Returning an error
+	// will terminate the handlers chain.
+	HandlerFunc func(*Context) error
+
+	// PanicHandler is called when a handler panics while resolving an update.
+	// It receives the recovered value and the stack trace of the panicking
+	// goroutine.
+	PanicHandler func(e *Bot, update *Update, recovered interface{}, stack []byte)
 )
 
+// ErrWebhookActive is returned when running in long polling mode while a
+// webhook is set.
+var ErrWebhookActive = errors.New("webhook is active, delete it to use long polling")
+
+// ErrHandled can be returned by a handler to terminate the handlers chain
+// once the update has been resolved. It is not reported as an error.
+var ErrHandled = errors.New("update handled")
+
+// DefaultAPIURL is the URL of the Telegram Bot API.
+const DefaultAPIURL = "https://api.telegram.org"
+
 func NewBot(token string) *Bot {
 	e := &Bot{
-		token: token,
+		token:  token,
+		apiURL: DefaultAPIURL,
+		client: http.DefaultClient,
 	}
 	return e
 }
 
+// Resolve an update with the handlers chain, between loading and saving its
+// session. A panic in any handler is recovered and reported to the panic
+// handler, so one bad update can not take the whole bot down.
 func (e *Bot) handle(update *Update) {
+	start := time.Now()
+	ctx, span := e.startUpdateSpan(context.Background(), update)
+	var failure error
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			if e.onPanic != nil {
+				e.onPanic(e, update, r, stack)
+			} else {
+				defaultPanicHandler(e, update, r, stack)
+			}
+			failure = fmt.Errorf("panic: %v", r)
+			if e.metrics != nil {
+				e.metrics.ObservePanic(update.Kind())
+			}
+		}
+		if e.metrics != nil {
+			e.metrics.ObserveUpdate(update.Kind(), time.Since(start), failure)
+		}
+		endSpan(span, failure)
+	}()
+	if e.recorder != nil {
+		if err := e.recorder.RecordUpdate(update); err != nil {
+			e.logError("record update", err, updateAttrs(update)...)
+		}
+	}
+	session, err := e.loadSession(update)
+	if err != nil {
+		e.logError("load session", err, updateAttrs(update)...)
+	}
+	c := newContext(ctx, e, update)
+	c.session = session
 	for _, handler := range e.handlers {
-		err := handler(e, update)
+		err := handler(c)
+		if err == ErrHandled {
+			break
+		}
 		if err != nil {
-			log.Println("Error:", err, "< handle")
+			failure = err
+			args := append(updateAttrs(update), slog.Duration("duration", time.Since(start)))
+			e.logError("handle update", err, args...)
 			break
 		}
 	}
+	e.Logger().Debug("update handled", append(updateAttrs(update),
+		slog.String("kind", update.Kind()),
+		slog.Duration("duration", time.Since(start)))...)
+	if err := e.saveSession(c.session); err != nil {
+		e.logError("save session", err, updateAttrs(update)...)
+	}
+}
+
+// SetDeleteWebhook makes RunLongPolling delete the webhook of the bot, if
+// set, instead of refusing to run.
+func (e *Bot) SetDeleteWebhook(enabled bool) {
+	e.deleteWebhook = enabled
 }
 
+// SetAPIURL makes the bot call the Bot API at url instead of DefaultAPIURL,
+// e.g. a local Bot API server or a fake one in tests.
+func (e *Bot) SetAPIURL(url string) {
+	e.apiURL = strings.TrimSuffix(url, "/")
+}
+
+// SetHTTPClient sets the HTTP client calling the Bot API, which defaults to
+// http.DefaultClient.
+func (e *Bot) SetHTTPClient(client *http.Client) {
+	e.client = client
+}
+
+// AddHandler appends a handler taking every kind of update to the handlers
+// chain.
 func (e *Bot) AddHandler(handler HandlerFunc) {
+	e.catchAll = true
 	e.handlers = append(e.handlers, handler)
 }
 
-func (e *Bot) RunWebhook(url string) {
-	// TODO
+// HandleUpdate resolves an update with the handlers chain, as if the bot had
+// received it, and returns once done.
+func (e *Bot) HandleUpdate(update *Update) {
+	e.handle(update)
+}
+
+// SetPanicHandler replaces the default panic handler, which logs the panic
+// with its stack trace.
+func (e *Bot) SetPanicHandler(handler PanicHandler) {
+	e.onPanic = handler
+}
+
+func defaultPanicHandler(e *Bot, update *Update, recovered interface{}, stack []byte) {
+	e.Logger().Error("handler panicked", append(updateAttrs(update),
+		slog.String("panic", e.redactString(fmt.Sprint(recovered))),
+		slog.String("stack", string(stack)))...)
 }
 
-func (e *Bot) RunLongPolling() {
-	log.Println("Info: Running in long polling mode.")
-	offset := 0
+// Run the bot in long polling mode. Polling goes on while workers resolve
+// the updates, but both the offset polled with and the offset store are kept
+// at the first update not yet resolved, see OffsetStore: Telegram delivers
+// the updates again after a crash, and the bot resolves them at least once.
+//
+// Telegram does not deliver updates through getUpdates while a webhook is
+// set: unless the bot deletes it, see SetDeleteWebhook, ErrWebhookActive is
+// returned. Otherwise the bot runs forever.
+func (e *Bot) RunLongPolling() error {
+	e.Logger().Info("running in long polling mode")
+	info, err := e.GetWebhookInfo()
+	if err != nil {
+		e.logError("get webhook info", err)
+	} else if info.URL != "" {
+		if !e.deleteWebhook {
+			return ErrWebhookActive
+		}
+		e.Logger().Info("deleting webhook", slog.String("url", e.redactString(info.URL)))
+		if err := e.DeleteWebhook(&DeleteWebhookRequest{}); err != nil {
+			return err
+		}
+	}
+	store := e.offsetStore
+	if store == nil {
+		store = NewMemoryOffsetStore()
+	}
+	offset, err := store.LoadOffset()
+	if err != nil {
+		e.logError("load offset", err)
+	}
+	tracker := newOffsetTracker(e, store, offset)
+	dispatcher := e.newDispatcher()
 	for {
-		updates, err := e.GetUpdates(offset, 100, 120)
+		// Poll from the lowest unfinished update, so that Telegram keeps the
+		// updates still being resolved. They are received again, and skipped.
+		offset := tracker.offset()
+		updates, err := e.GetUpdates(&GetUpdatesRequest{
+			Offset:         offset,
+			Limit:          100,
+			Timeout:        120,
+			AllowedUpdates: e.AllowedUpdates(),
+		})
 		if err != nil {
-			log.Println("Error:", err, "< GetUpdates < RunLongPolling")
+			e.logError("get updates", err, slog.Int("offset", offset))
+			if e.metrics != nil {
+				e.metrics.ObserveRetry("getUpdates")
+			}
 			time.Sleep(time.Second)
 			continue
 		}
-		for _, update := range updates {
-			e.handle(&update)
-			if offset < (update.UpdateID + 1) {
-				offset = update.UpdateID + 1
+		started := 0
+		for i := range updates {
+			update := &updates[i]
+			id := update.UpdateID
+			if !tracker.start(id) {
+				continue
 			}
+			started++
+			if dispatcher != nil {
+				dispatcher.dispatch(update, func() { tracker.finish(id) })
+			} else {
+				e.handle(update)
+				tracker.finish(id)
+			}
+		}
+		// Telegram answers at once while unfinished updates are pending: wait
+		// for one to finish before polling again, but not so long that new
+		// updates are delayed.
+		if started == 0 && len(updates) > 0 {
+			tracker.wait(pollInterval)
 		}
 	}
 }
@@ -0,0 +1,79 @@
+package bottest
+
+import bot "github.com/magicae/telegram-bot"
+
+// Harness drives the handlers chain of a bot with updates, directly rather
+// than through getUpdates, and captures the API calls each update produces.
+//
+//	h := bottest.NewHarness(e)
+//	defer h.Close()
+//	alice := bottest.NewUser(1, "Alice")
+//	calls := h.Send(bottest.TextUpdate(alice, bottest.PrivateChat(alice), "/start"))
+type Harness struct {
+	// Fake server the bot calls.
+	Server *Server
+	// Bot under test.
+	Bot *bot.Bot
+}
+
+// NewHarness makes the bot call a new fake server.
+func NewHarness(e *bot.Bot) *Harness {
+	s := NewServer()
+	e.SetAPIURL(s.URL())
+	return &Harness{Server: s, Bot: e}
+}
+
+// Close shuts the fake server down.
+func (h *Harness) Close() {
+	h.Server.Close()
+}
+
+// Send resolves the update with the handlers chain of the bot and returns the
+// API calls made meanwhile. An update without identifier gets the next one.
+func (h *Harness) Send(update *bot.Update) []*Call {
+	h.Server.mutex.Lock()
+	if update.UpdateID == 0 {
+		update.UpdateID = h.Server.nextUpdateID
+	}
+	if update.UpdateID >= h.Server.nextUpdateID {
+		h.Server.nextUpdateID = update.UpdateID + 1
+	}
+	before := len(h.Server.calls)
+	h.Server.mutex.Unlock()
+
+	h.Bot.HandleUpdate(update)
+
+	h.Server.mutex.Lock()
+	defer h.Server.mutex.Unlock()
+	return append([]*Call(nil), h.Server.calls[before:]...)
+}
+
+// Texts returns the texts sent by the sendMessage and editMessageText calls,
+// in order.
+func Texts(calls []*Call) []string {
+	var texts []string
+	for _, call := range calls {
+		if call.Method != "sendMessage" && call.Method != "editMessageText" {
+			continue
+		}
+		var body struct {
+			Text string `json:"text"`
+		}
+		if call.Decode(&body) == nil {
+			texts = append(texts, body.Text)
+		}
+	}
+	return texts
+}
+
+// SentMessages returns the messages sent by the calls, as returned by the
+// server.
+func SentMessages(calls []*Call) []*bot.Message {
+	var messages []*bot.Message
+	for _, call := range calls {
+		if message, ok := call.Result.(*bot.Message); ok {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
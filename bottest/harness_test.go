@@ -0,0 +1,94 @@
+package bottest
+
+import (
+	"reflect"
+	"testing"
+
+	bot "github.com/magicae/telegram-bot"
+)
+
+// Bot taking pizza orders through a conversation, greeting mentioned users
+// and answering /help anywhere in a message.
+func newPizzaBot() *bot.Bot {
+	order := &bot.Conversation{
+		Name:     "order",
+		Commands: []string{"/order"},
+		Start:    "size",
+		Steps: map[string]*bot.Step{
+			"size": {
+				Enter: func(c *bot.Context, state *bot.ConversationState) error {
+					_, err := c.Reply("What size?")
+					return err
+				},
+				OnText: func(c *bot.Context, state *bot.ConversationState, input string) (string, error) {
+					state.Data["size"] = input
+					return "color", nil
+				},
+			},
+			"color": {
+				Enter: func(c *bot.Context, state *bot.ConversationState) error {
+					_, err := c.Reply("Which color?")
+					return err
+				},
+				OnText: func(c *bot.Context, state *bot.ConversationState, input string) (string, error) {
+					_, err := c.Reply("Ordered a " + state.Data["size"] + " " + input + " pizza.")
+					return bot.ConversationEnd, err
+				},
+			},
+		},
+		OnCancel: func(c *bot.Context, state *bot.ConversationState) error {
+			_, err := c.Reply("Cancelled.")
+			return err
+		},
+	}
+	e := bot.NewBot("")
+	e.SetLogger(nil)
+	e.AddHandler(order.Handler())
+	e.AddHandler(func(c *bot.Context) error {
+		message := c.Message()
+		if message == nil {
+			return nil
+		}
+		for _, command := range message.Commands() {
+			if command == "/help" {
+				if _, err := c.Reply("Send /order to order a pizza."); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mention := range message.Mentions() {
+			if _, err := c.Reply("Hello " + mention + "!"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return e
+}
+
+func TestHarnessConversation(t *testing.T) {
+	h := NewHarness(newPizzaBot())
+	defer h.Close()
+	alice := NewUser(1, "Alice")
+	chat := PrivateChat(alice)
+	steps := []struct {
+		text string
+		want []string
+	}{
+		{"/order", []string{"What size?"}},
+		{"large", []string{"Which color?"}},
+		{"red", []string{"Ordered a large red pizza."}},
+		{"thanks", nil},
+		{"thanks\n/help", []string{"Send /order to order a pizza."}},
+		{"hi @bob\n@carol", []string{"Hello @bob!", "Hello @carol!"}},
+		{"/order", []string{"What size?"}},
+		{"/cancel", []string{"Cancelled."}},
+		{"small", nil},
+	}
+	for i, step := range steps {
+		calls := h.Send(TextUpdate(alice, chat, step.text))
+		if got := Texts(calls); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("step %d %q: got %q, want %q", i, step.text, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,543 @@
+// Package bottest provides an in-process fake of the Telegram Bot API, to
+// test bots without reaching api.telegram.org.
+package bottest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bot "github.com/magicae/telegram-bot"
+)
+
+// Token is the token of the bots returned by Server.Bot.
+const Token = "123456:TEST-TOKEN"
+
+type (
+	// Server is a fake Bot API server. It records every call, answers them
+	// with plausible results and delivers the updates pushed by the test
+	// through getUpdates.
+	Server struct {
+		server  *httptest.Server
+		mutex   sync.Mutex
+		methods map[string]MethodFunc
+		calls   []*Call
+		me      bot.User
+		webhook bot.WebhookInfo
+		// Updates not confirmed by getUpdates yet.
+		updates       []bot.Update
+		nextUpdateID  int
+		nextMessageID int
+		// Closed and replaced every time an update is pushed.
+		pushed chan struct{}
+		closed chan struct{}
+	}
+
+	// Call is a call of a Bot API method received by the server.
+	Call struct {
+		// Name of the method.
+		Method string
+		// Token of the bot.
+		Token string
+		// Raw JSON body of the request. The fields of multipart/form-data
+		// requests are converted to JSON.
+		Body []byte
+		// Files uploaded with multipart/form-data, by field name.
+		Files map[string][]byte
+		// Result returned by the server, or nil if the call failed.
+		Result interface{}
+	}
+
+	// MethodFunc implements a Bot API method. It returns the result of the
+	// call, or an error whose message is sent as the description of the
+	// failure.
+	MethodFunc func(s *Server, call *Call) (interface{}, error)
+)
+
+// NewServer starts a server implementing getMe, getUpdates, the webhook
+// methods, sendMessage, forwardMessage, sendSticker, sendDocument,
+// answerCallbackQuery and the methods editing messages. It must be closed
+// once done.
+func NewServer() *Server {
+	s := &Server{
+		me: bot.User{
+			ID:        123456,
+			FirstName: "Test",
+			Username:  "test_bot",
+		},
+		nextUpdateID:  1,
+		nextMessageID: 1,
+		pushed:        make(chan struct{}),
+		closed:        make(chan struct{}),
+	}
+	s.methods = map[string]MethodFunc{
+		"getMe":                  getMe,
+		"getUpdates":             getUpdates,
+		"sendMessage":            sendMessage,
+		"forwardMessage":         forwardMessage,
+		"sendSticker":            sendSticker,
+		"sendDocument":           sendDocument,
+		"setWebhook":             setWebhook,
+		"deleteWebhook":          deleteWebhook,
+		"getWebhookInfo":         getWebhookInfo,
+		"answerCallbackQuery":    answerCallbackQuery,
+		"editMessageText":        editMessage,
+		"editMessageCaption":     editMessage,
+		"editMessageReplyMarkup": editMessage,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the URL of the server, to be given to Bot.SetAPIURL.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close shuts the server down, releasing the pending getUpdates calls.
+func (s *Server) Close() {
+	s.mutex.Lock()
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	s.mutex.Unlock()
+	s.server.Close()
+}
+
+// Bot returns a new bot calling the server.
+func (s *Server) Bot() *bot.Bot {
+	e := bot.NewBot(Token)
+	e.SetAPIURL(s.URL())
+	return e
+}
+
+// Me returns the user returned by getMe, which is also the sender of the
+// messages sent by the bot.
+func (s *Server) Me() bot.User {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.me
+}
+
+// SetMe sets the user returned by getMe.
+func (s *Server) SetMe(me bot.User) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.me = me
+}
+
+// Handle implements a method, replacing the default implementation if any.
+// It can be used to make a method fail or to support more methods.
+func (s *Server) Handle(method string, fn MethodFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.methods[method] = fn
+}
+
+// PushUpdate queues an update to be delivered by getUpdates. An update
+// without identifier gets the next one. Returns the identifier.
+func (s *Server) PushUpdate(update bot.Update) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if update.UpdateID == 0 {
+		update.UpdateID = s.nextUpdateID
+	}
+	if update.UpdateID >= s.nextUpdateID {
+		s.nextUpdateID = update.UpdateID + 1
+	}
+	s.updates = append(s.updates, update)
+	close(s.pushed)
+	s.pushed = make(chan struct{})
+	return update.UpdateID
+}
+
+// Pending returns the number of updates not confirmed by getUpdates yet.
+func (s *Server) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.updates)
+}
+
+// Calls returns the calls received so far, in order.
+func (s *Server) Calls() []*Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*Call(nil), s.calls...)
+}
+
+// CallsTo returns the calls of the method received so far, in order.
+func (s *Server) CallsTo(method string) []*Call {
+	var calls []*Call
+	for _, call := range s.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the calls received so far.
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls = nil
+}
+
+// NextMessageID returns a new message identifier.
+func (s *Server) NextMessageID() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id := s.nextMessageID
+	s.nextMessageID++
+	return id
+}
+
+// Decode decodes the parameters of the call into v, usually a pointer to the
+// request type of the method.
+func (c *Call) Decode(v interface{}) error {
+	if len(c.Body) == 0 || string(c.Body) == "null" {
+		return nil
+	}
+	return json.Unmarshal(c.Body, v)
+}
+
+// Params returns the parameters of the call. Numbers are json.Number.
+func (c *Call) Params() map[string]interface{} {
+	params := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(c.Body))
+	decoder.UseNumber()
+	decoder.Decode(&params)
+	return params
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// Paths are /bot<token>/<method>.
+	path := strings.TrimPrefix(r.URL.Path, "/bot")
+	i := strings.LastIndex(path, "/")
+	if i < 0 || path == r.URL.Path {
+		writeResponse(w, http.StatusNotFound, errors.New("Not Found"), nil)
+		return
+	}
+	call := &Call{
+		Method: path[i+1:],
+		Token:  path[:i],
+	}
+	if err := readRequest(r, call); err != nil {
+		writeResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	s.mutex.Lock()
+	fn := s.methods[call.Method]
+	s.calls = append(s.calls, call)
+	s.mutex.Unlock()
+	if fn == nil {
+		writeResponse(w, http.StatusNotFound, errors.New("Not Found: method not found"), nil)
+		return
+	}
+	result, err := fn(s, call)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	s.mutex.Lock()
+	call.Result = result
+	s.mutex.Unlock()
+	writeResponse(w, http.StatusOK, nil, result)
+}
+
+// Request types of the methods the bot package may call with
+// multipart/form-data, whose fields tell the types of the form fields.
+var multipartRequests = map[string]interface{}{
+	"setWebhook":   bot.SetWebhookRequest{},
+	"sendDocument": bot.SendDocumentRequest{},
+}
+
+// Read the body of the request into the call. Fields of multipart/form-data
+// requests are strings, except for the parameters of the method which are
+// not strings in its request type: those are sent as JSON and kept as is.
+// For other methods, fields which are valid JSON are kept as is.
+func readRequest(r *http.Request, call *Call) error {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		body, err := ioutil.ReadAll(r.Body)
+		call.Body = body
+		return err
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return err
+	}
+	params, known := jsonParams(call.Method)
+	fields := make(map[string]json.RawMessage)
+	for name, values := range r.MultipartForm.Value {
+		value := []byte(values[0])
+		if known && !params[name] || !known && !json.Valid(value) {
+			value, _ = json.Marshal(values[0])
+		}
+		fields[name] = value
+	}
+	call.Files = make(map[string][]byte)
+	for name, headers := range r.MultipartForm.File {
+		f, err := headers[0].Open()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		call.Files[name] = data
+	}
+	body, err := json.Marshal(fields)
+	call.Body = body
+	return err
+}
+
+// Names of the parameters of a method which are not strings, and whether
+// its request type is known.
+func jsonParams(method string) (map[string]bool, bool) {
+	request, ok := multipartRequests[method]
+	if !ok {
+		return nil, false
+	}
+	params := make(map[string]bool)
+	t := reflect.TypeOf(request)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		kind := t.Field(i).Type.Kind()
+		if name != "" && name != "-" && kind != reflect.String {
+			params[name] = true
+		}
+	}
+	return params, true
+}
+
+func writeResponse(w http.ResponseWriter, code int, err error, result interface{}) {
+	res := map[string]interface{}{"ok": err == nil}
+	if err != nil {
+		res["error_code"] = code
+		res["description"] = err.Error()
+	} else {
+		res["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+func getMe(s *Server, call *Call) (interface{}, error) {
+	me := s.Me()
+	return &me, nil
+}
+
+// Confirm the updates before the offset and return the next ones, waiting
+// for some to be pushed for at most the timeout.
+func getUpdates(s *Server, call *Call) (interface{}, error) {
+	req := &bot.GetUpdatesRequest{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	if s.Webhook().URL != "" {
+		return nil, errors.New("Conflict: can't use getUpdates method while webhook is active; use deleteWebhook to delete the webhook first")
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	deadline := time.After(time.Duration(req.Timeout) * time.Second)
+	for {
+		s.mutex.Lock()
+		for len(s.updates) > 0 && s.updates[0].UpdateID < req.Offset {
+			s.updates = s.updates[1:]
+		}
+		if len(s.updates) > 0 || req.Timeout <= 0 {
+			updates := s.updates
+			if len(updates) > limit {
+				updates = updates[:limit]
+			}
+			s.mutex.Unlock()
+			return append([]bot.Update{}, updates...), nil
+		}
+		pushed := s.pushed
+		s.mutex.Unlock()
+		select {
+		case <-pushed:
+		case <-s.closed:
+			return []bot.Update{}, nil
+		case <-deadline:
+			req.Timeout = 0
+		}
+	}
+}
+
+// Webhook returns the status of the webhook of the bot.
+func (s *Server) Webhook() bot.WebhookInfo {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.webhook
+}
+
+// Set the webhook. Updates are not delivered to it, but getUpdates fails
+// while it is set, as with Telegram.
+func setWebhook(s *Server, call *Call) (interface{}, error) {
+	req := &bot.SetWebhookRequest{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	if req.URL != "" && !strings.HasPrefix(req.URL, "https://") {
+		return nil, errors.New("Bad Request: bad webhook: An HTTPS URL must be provided for webhook")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.webhook = bot.WebhookInfo{
+		URL:                  req.URL,
+		HasCustomCertificate: call.Files["certificate"] != nil,
+		MaxConnections:       req.MaxConnections,
+		AllowedUpdates:       req.AllowedUpdates,
+		IPAddress:            req.IPAddress,
+	}
+	if req.DropPendingUpdates {
+		s.updates = nil
+	}
+	return true, nil
+}
+
+func deleteWebhook(s *Server, call *Call) (interface{}, error) {
+	req := &bot.DeleteWebhookRequest{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.webhook = bot.WebhookInfo{}
+	if req.DropPendingUpdates {
+		s.updates = nil
+	}
+	return true, nil
+}
+
+func getWebhookInfo(s *Server, call *Call) (interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	info := s.webhook
+	info.PendingUpdateCount = len(s.updates)
+	return &info, nil
+}
+
+func sendMessage(s *Server, call *Call) (interface{}, error) {
+	req := &bot.SendMessageRequest{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	if req.Text == "" {
+		return nil, errors.New("Bad Request: message text is empty")
+	}
+	message := s.newMessage(req.ChatID)
+	message.Text = req.Text
+	message.Entities = req.Entities
+	return message, nil
+}
+
+func forwardMessage(s *Server, call *Call) (interface{}, error) {
+	req := &bot.ForwardMessageRequest{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	message := s.newMessage(req.ChatID)
+	message.ForwardFromChat = &bot.Chat{ID: req.FromChatID}
+	message.ForwardDate = message.Date
+	return message, nil
+}
+
+func sendSticker(s *Server, call *Call) (interface{}, error) {
+	req := &bot.SendStickerRequest{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	message := s.newMessage(req.ChatID)
+	message.Sticker = &bot.Sticker{FileID: req.Sticker}
+	return message, nil
+}
+
+func sendDocument(s *Server, call *Call) (interface{}, error) {
+	req := &bot.SendDocumentRequest{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	document := &bot.Document{FileID: req.Document}
+	if data, ok := call.Files["document"]; ok {
+		document.FileID = "upload-" + strconv.Itoa(s.NextMessageID())
+		document.FileSize = len(data)
+	}
+	if document.FileID == "" {
+		return nil, errors.New("Bad Request: there is no document in the request")
+	}
+	message := s.newMessage(req.ChatID)
+	message.Document = document
+	message.Caption = req.Caption
+	return message, nil
+}
+
+func answerCallbackQuery(s *Server, call *Call) (interface{}, error) {
+	req := &bot.AnswerCallbackQueryRequest{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	if req.CallbackQueryID == "" {
+		return nil, errors.New("Bad Request: query is too old and response timeout expired or query ID is invalid")
+	}
+	return true, nil
+}
+
+// Edit a message, returning true for inline messages like Telegram does.
+func editMessage(s *Server, call *Call) (interface{}, error) {
+	req := &struct {
+		ChatID          int64                     `json:"chat_id"`
+		MessageID       int                       `json:"message_id"`
+		InlineMessageID string                    `json:"inline_message_id"`
+		Text            string                    `json:"text"`
+		Entities        []bot.MessageEntity       `json:"entities"`
+		Caption         string                    `json:"caption"`
+		ReplyMarkup     *bot.InlineKeyboardMarkup `json:"reply_markup"`
+	}{}
+	if err := call.Decode(req); err != nil {
+		return nil, err
+	}
+	if req.InlineMessageID != "" {
+		return true, nil
+	}
+	if req.ChatID == 0 || req.MessageID == 0 {
+		return nil, errors.New("Bad Request: message to edit not found")
+	}
+	me := s.Me()
+	return &bot.Message{
+		MessageID: req.MessageID,
+		From:      &me,
+		Date:      uint64(time.Now().Unix()),
+		EditDate:  uint64(time.Now().Unix()),
+		Chat:      &bot.Chat{ID: req.ChatID},
+		Text:      req.Text,
+		Entities:  req.Entities,
+		Caption:   req.Caption,
+	}, nil
+}
+
+// New message sent by the bot to the chat.
+func (s *Server) newMessage(chatID int64) *bot.Message {
+	me := s.Me()
+	return &bot.Message{
+		MessageID: s.NextMessageID(),
+		From:      &me,
+		Date:      uint64(time.Now().Unix()),
+		Chat:      &bot.Chat{ID: chatID},
+	}
+}
@@ -0,0 +1,161 @@
+package bottest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	bot "github.com/magicae/telegram-bot"
+)
+
+func newTestBot(t *testing.T) (*Server, *bot.Bot) {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	e := s.Bot()
+	e.SetLogger(nil)
+	return s, e
+}
+
+func TestGetUpdatesWaitsForPush(t *testing.T) {
+	s, e := newTestBot(t)
+	user := NewUser(1, "alice")
+	done := make(chan []bot.Update)
+	go func() {
+		updates, err := e.GetUpdates(&bot.GetUpdatesRequest{Timeout: 5})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- updates
+	}()
+	select {
+	case <-done:
+		t.Fatal("getUpdates returned before an update was pushed")
+	case <-time.After(200 * time.Millisecond):
+	}
+	id := s.PushUpdate(*TextUpdate(user, PrivateChat(user), "hello"))
+	select {
+	case updates := <-done:
+		if len(updates) != 1 || updates[0].UpdateID != id || updates[0].Message.Text != "hello" {
+			t.Fatalf("got %+v, want the pushed update", updates)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("getUpdates did not return once an update was pushed")
+	}
+}
+
+func TestGetUpdatesTimeout(t *testing.T) {
+	_, e := newTestBot(t)
+	start := time.Now()
+	updates, err := e.GetUpdates(&bot.GetUpdatesRequest{Timeout: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 0 {
+		t.Fatalf("got %d updates, want none", len(updates))
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Fatalf("getUpdates returned after %v, want about 1s", elapsed)
+	}
+}
+
+func TestGetUpdatesOffsetConfirms(t *testing.T) {
+	s, e := newTestBot(t)
+	user := NewUser(1, "alice")
+	for _, text := range []string{"one", "two", "three"} {
+		s.PushUpdate(*TextUpdate(user, PrivateChat(user), text))
+	}
+	updates, err := e.GetUpdates(&bot.GetUpdatesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 3 || s.Pending() != 3 {
+		t.Fatalf("got %d updates and %d pending, want 3 and 3", len(updates), s.Pending())
+	}
+	updates, err = e.GetUpdates(&bot.GetUpdatesRequest{Offset: updates[1].UpdateID + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].Message.Text != "three" {
+		t.Fatalf("got %+v, want only the third update", updates)
+	}
+	if s.Pending() != 1 {
+		t.Fatalf("got %d pending updates, want 1", s.Pending())
+	}
+}
+
+func TestGetUpdatesFailsWithWebhook(t *testing.T) {
+	s, e := newTestBot(t)
+	if err := e.SetWebhook(&bot.SetWebhookRequest{URL: "https://example.com/hook"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Webhook().URL != "https://example.com/hook" {
+		t.Fatalf("got webhook %q, want it set", s.Webhook().URL)
+	}
+	_, err := e.GetUpdates(&bot.GetUpdatesRequest{})
+	if err == nil || !strings.HasPrefix(err.Error(), "Conflict") {
+		t.Fatalf("got error %v, want a conflict", err)
+	}
+	if err := e.DeleteWebhook(&bot.DeleteWebhookRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.GetUpdates(&bot.GetUpdatesRequest{}); err != nil {
+		t.Fatalf("got error %v once the webhook is deleted", err)
+	}
+}
+
+func TestSendDocumentMultipart(t *testing.T) {
+	s, e := newTestBot(t)
+	message, err := e.SendDocument(&bot.SendDocumentRequest{
+		ChatID:       42,
+		Caption:      "report",
+		DocumentFile: &bot.InputFile{Name: "report.txt", Reader: strings.NewReader("contents")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Document == nil || message.Document.FileSize != len("contents") {
+		t.Fatalf("got document %+v, want the uploaded one", message.Document)
+	}
+	calls := s.CallsTo("sendDocument")
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(calls))
+	}
+	if got := string(calls[0].Files["document"]); got != "contents" {
+		t.Errorf("got file %q, want %q", got, "contents")
+	}
+	req := &bot.SendDocumentRequest{}
+	if err := calls[0].Decode(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.ChatID != 42 || req.Caption != "report" {
+		t.Errorf("got body %s, want chat_id 42 and caption report", calls[0].Body)
+	}
+}
+
+func TestSendDocumentMultipartTypes(t *testing.T) {
+	s, e := newTestBot(t)
+	markup := &bot.InlineKeyboardMarkup{InlineKeyboard: [][]*bot.InlineKeyboardButton{{{Text: "ok", CallbackData: "ok"}}}}
+	for _, caption := range []string{"42", "true", `{"a":1}`} {
+		s.Reset()
+		_, err := e.SendDocument(&bot.SendDocumentRequest{
+			ChatID:       42,
+			Caption:      caption,
+			ReplyMarkup:  markup,
+			DocumentFile: &bot.InputFile{Name: "a.txt", Reader: strings.NewReader("a")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := s.CallsTo("sendDocument")[0].Params()
+		if got, ok := params["caption"].(string); !ok || got != caption {
+			t.Errorf("got caption %#v, want the string %q", params["caption"], caption)
+		}
+		if _, ok := params["chat_id"].(json.Number); !ok {
+			t.Errorf("got chat_id %#v, want a number", params["chat_id"])
+		}
+		if _, ok := params["reply_markup"].(map[string]interface{}); !ok {
+			t.Errorf("got reply_markup %#v, want an object", params["reply_markup"])
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package bottest
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	bot "github.com/magicae/telegram-bot"
+)
+
+// Identifiers of the messages and callback queries built by this package.
+// Messages sent by the server get other ones.
+var (
+	lastMessageID  int64 = 1000000
+	lastCallbackID int64
+)
+
+// NewUser returns a user with the identifier and first name, whose username
+// is the lowercase first name.
+func NewUser(id int, firstName string) *bot.User {
+	return &bot.User{
+		ID:        id,
+		FirstName: firstName,
+		Username:  strings.ToLower(firstName),
+	}
+}
+
+// PrivateChat returns the private chat with the user.
+func PrivateChat(user *bot.User) *bot.Chat {
+	return &bot.Chat{
+		ID:        int64(user.ID),
+		Type:      "private",
+		Username:  user.Username,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	}
+}
+
+// Group returns a group chat. Group identifiers are negative.
+func Group(id int64, title string) *bot.Chat {
+	return &bot.Chat{ID: id, Type: "group", Title: title}
+}
+
+// Supergroup returns a supergroup chat. Supergroup identifiers are negative.
+func Supergroup(id int64, title string) *bot.Chat {
+	return &bot.Chat{ID: id, Type: "supergroup", Title: title}
+}
+
+// NewMessage returns a message sent by the user to the chat, without content.
+func NewMessage(from *bot.User, chat *bot.Chat) *bot.Message {
+	return &bot.Message{
+		MessageID: int(atomic.AddInt64(&lastMessageID, 1)),
+		From:      from,
+		Date:      uint64(time.Now().Unix()),
+		Chat:      chat,
+	}
+}
+
+// TextMessage returns a text message sent by the user to the chat. Bot
+// commands, mentions, hashtags and URLs in the text get their entities.
+func TextMessage(from *bot.User, chat *bot.Chat, text string) *bot.Message {
+	message := NewMessage(from, chat)
+	message.Text = text
+	message.Entities = detectEntities(text)
+	return message
+}
+
+// TextUpdate returns an update with a text message sent by the user to the
+// chat.
+func TextUpdate(from *bot.User, chat *bot.Chat, text string) *bot.Update {
+	return &bot.Update{Message: TextMessage(from, chat, text)}
+}
+
+// EditedTextUpdate returns an update with a new version of a text message.
+func EditedTextUpdate(message *bot.Message, text string) *bot.Update {
+	edited := *message
+	edited.Text = text
+	edited.Entities = detectEntities(text)
+	edited.EditDate = uint64(time.Now().Unix())
+	return &bot.Update{EditedMessage: &edited}
+}
+
+// CallbackUpdate returns an update with the user pressing a callback button
+// of the message.
+func CallbackUpdate(from *bot.User, message *bot.Message, data string) *bot.Update {
+	id := strconv.FormatInt(atomic.AddInt64(&lastCallbackID, 1), 10)
+	query := &bot.CallbackQuery{
+		ID:      id,
+		From:    from,
+		Message: message,
+		Data:    data,
+	}
+	if message != nil && message.Chat != nil {
+		query.ChatInstance = strconv.FormatInt(message.Chat.ID, 10)
+	}
+	return &bot.Update{CallbackQuery: query}
+}
+
+// InlineQueryUpdate returns an update with an inline query of the user.
+func InlineQueryUpdate(from *bot.User, query string) *bot.Update {
+	id := strconv.FormatInt(atomic.AddInt64(&lastCallbackID, 1), 10)
+	return &bot.Update{InlineQuery: &bot.InlineQuery{
+		ID:    id,
+		From:  from,
+		Query: query,
+	}}
+}
+
+// NewMemberUpdate returns an update with the service message of the member
+// joining the chat.
+func NewMemberUpdate(chat *bot.Chat, member *bot.User) *bot.Update {
+	message := NewMessage(member, chat)
+	message.NewChatMember = member
+	return &bot.Update{Message: message}
+}
+
+// LeftMemberUpdate returns an update with the service message of the member
+// leaving the chat.
+func LeftMemberUpdate(chat *bot.Chat, member *bot.User) *bot.Update {
+	message := NewMessage(member, chat)
+	message.LeftChatMember = member
+	return &bot.Update{Message: message}
+}
+
+// Entities of the words of the text which Telegram would detect. Words are
+// separated by any white space, including new lines.
+func detectEntities(text string) []bot.MessageEntity {
+	var entities []bot.MessageEntity
+	offset := 0
+	for len(text) > 0 {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			end = len(text)
+		}
+		word := text[:end]
+		entity := bot.MessageEntity{
+			Offset: offset,
+			Length: bot.UTF16Len(word),
+		}
+		switch {
+		case len(word) < 2:
+		case word[0] == '/':
+			entity.Type = bot.EntityBotCommand
+		case word[0] == '@':
+			entity.Type = bot.EntityMention
+		case word[0] == '#':
+			entity.Type = bot.EntityHashtag
+		case strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://"):
+			entity.Type = bot.EntityURL
+		}
+		if entity.Type != "" {
+			entities = append(entities, entity)
+		}
+		offset += entity.Length
+		text = text[end:]
+		// Skip the white space following the word.
+		for len(text) > 0 {
+			r, size := utf8.DecodeRuneInString(text)
+			if !unicode.IsSpace(r) {
+				break
+			}
+			offset += bot.UTF16Len(text[:size])
+			text = text[size:]
+		}
+	}
+	return entities
+}
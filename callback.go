@@ -0,0 +1,376 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxCallbackDataLength is the maximum length of the data of a callback
+// button, in bytes.
+const MaxCallbackDataLength = 64
+
+const (
+	// Length of the encoded signature of callback data.
+	callbackSignatureLength = 8
+	// Marks callback data kept in the store.
+	callbackStoredMark = "~"
+)
+
+type (
+	// CallbackCodec packs structs into callback data and back. The data is
+	// made of a prefix identifying the kind of payload followed by the
+	// exported fields of the struct, in order. Fields can be strings,
+	// booleans, integers or floats.
+	//
+	// If the codec has a secret, the data is signed with it, so that data
+	// forged by a client is rejected. Data longer than MaxCallbackDataLength
+	// is kept in a store and replaced by its identifier.
+	CallbackCodec struct {
+		secret []byte
+		store  CallbackStore
+		routes map[string]func(c *Context, fields []string) error
+	}
+
+	// CallbackStore keeps the callback data too long to fit in a button.
+	CallbackStore interface {
+		// PutCallbackData stores the data and returns its identifier, which
+		// must be short and must not contain “|”.
+		PutCallbackData(data string) (string, error)
+		// GetCallbackData returns the data stored with the identifier, or
+		// ErrCallbackDataExpired.
+		GetCallbackData(id string) (string, error)
+	}
+
+	// MemoryCallbackStore keeps callback data in memory for a while.
+	MemoryCallbackStore struct {
+		mutex   sync.Mutex
+		ttl     time.Duration
+		entries map[string]callbackEntry
+		// Time the expired entries were last dropped.
+		swept time.Time
+	}
+
+	callbackEntry struct {
+		data    string
+		expires time.Time
+	}
+)
+
+var (
+	// ErrInvalidCallbackData is returned when decoding callback data which
+	// was not encoded by the codec or does not match the payload.
+	ErrInvalidCallbackData = errors.New("invalid callback data")
+	// ErrCallbackDataExpired is returned when decoding callback data which
+	// is no longer in the store.
+	ErrCallbackDataExpired = errors.New("callback data expired")
+)
+
+// NewCallbackCodec returns a codec signing data with the secret, or not
+// signing it if the secret is empty. Long data is kept in memory for a day.
+func NewCallbackCodec(secret []byte) *CallbackCodec {
+	return &CallbackCodec{
+		secret: secret,
+		store:  NewMemoryCallbackStore(24 * time.Hour),
+		routes: make(map[string]func(c *Context, fields []string) error),
+	}
+}
+
+// SetStore replaces the store of long callback data.
+func (c *CallbackCodec) SetStore(store CallbackStore) {
+	c.store = store
+}
+
+// Encode packs the prefix and the struct v, or a pointer to it, into
+// callback data. v may be nil, or a nil pointer, for payloads without
+// fields. The prefix must not contain “|” nor start with “~”.
+func (c *CallbackCodec) Encode(prefix string, v interface{}) (string, error) {
+	if strings.Contains(prefix, "|") || strings.HasPrefix(prefix, callbackStoredMark) {
+		return "", fmt.Errorf("invalid callback prefix %q", prefix)
+	}
+	fields, err := encodeCallbackFields(v)
+	if err != nil {
+		return "", err
+	}
+	payload := joinCallbackFields(append([]string{prefix}, fields...))
+	data := c.sign(payload)
+	if len(data) <= MaxCallbackDataLength {
+		return data, nil
+	}
+	id, err := c.store.PutCallbackData(payload)
+	if err != nil {
+		return "", err
+	}
+	return c.sign(callbackStoredMark + id), nil
+}
+
+// Decode unpacks callback data into the struct pointed to by v, which may be
+// nil to only get the prefix. Returns the prefix of the data.
+func (c *CallbackCodec) Decode(data string, v interface{}) (string, error) {
+	fields, err := c.open(data)
+	if err != nil {
+		return "", err
+	}
+	if v != nil {
+		if err := decodeCallbackFields(fields[1:], v); err != nil {
+			return "", err
+		}
+	}
+	return fields[0], nil
+}
+
+// HandleCallback routes the callback queries whose data has the prefix to
+// the handler, with the payload decoded.
+func HandleCallback[T any](c *CallbackCodec, prefix string, handler func(ctx *Context, payload *T) error) {
+	c.routes[prefix] = func(ctx *Context, fields []string) error {
+		payload := new(T)
+		if err := decodeCallbackFields(fields, payload); err != nil {
+			return err
+		}
+		return handler(ctx, payload)
+	}
+}
+
+// Handler returns the handler routing callback queries to the handlers
+// registered with HandleCallback. Callback queries with data of unknown
+// prefixes, or which was not encoded by the codec, go on to the next
+// handlers.
+func (c *CallbackCodec) Handler() HandlerFunc {
+	return func(ctx *Context) error {
+		query := ctx.Update.CallbackQuery
+		if query == nil || query.Data == "" {
+			return nil
+		}
+		fields, err := c.open(query.Data)
+		if err == ErrInvalidCallbackData {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		route, ok := c.routes[fields[0]]
+		if !ok {
+			return nil
+		}
+		if err := route(ctx, fields[1:]); err != nil {
+			return err
+		}
+		return ErrHandled
+	}
+}
+
+// Check the signature of data and split it into the prefix and fields,
+// fetching it from the store if needed.
+func (c *CallbackCodec) open(data string) ([]string, error) {
+	payload, err := c.verify(data)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(payload, callbackStoredMark) {
+		payload, err = c.store.GetCallbackData(payload[len(callbackStoredMark):])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return splitCallbackFields(payload)
+}
+
+func (c *CallbackCodec) sign(payload string) string {
+	if len(c.secret) == 0 {
+		return payload
+	}
+	return c.signature(payload) + payload
+}
+
+func (c *CallbackCodec) verify(data string) (string, error) {
+	if len(c.secret) == 0 {
+		return data, nil
+	}
+	if len(data) < callbackSignatureLength {
+		return "", ErrInvalidCallbackData
+	}
+	signature, payload := data[:callbackSignatureLength], data[callbackSignatureLength:]
+	if !hmac.Equal([]byte(signature), []byte(c.signature(payload))) {
+		return "", ErrInvalidCallbackData
+	}
+	return payload, nil
+}
+
+// Truncated HMAC of the payload, encoded in callbackSignatureLength bytes.
+func (c *CallbackCodec) signature(payload string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:6])
+}
+
+func encodeCallbackFields(v interface{}) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if !value.IsValid() {
+		// A nil pointer, as a payload without fields.
+		return nil, nil
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("callback payload must be a struct, not %s", value.Type())
+	}
+	var fields []string
+	for i := 0; i < value.NumField(); i++ {
+		if !value.Type().Field(i).IsExported() {
+			continue
+		}
+		field := value.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			fields = append(fields, field.String())
+		case reflect.Bool:
+			if field.Bool() {
+				fields = append(fields, "1")
+			} else {
+				fields = append(fields, "")
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fields = append(fields, strconv.FormatInt(field.Int(), 36))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fields = append(fields, strconv.FormatUint(field.Uint(), 36))
+		case reflect.Float32, reflect.Float64:
+			fields = append(fields, strconv.FormatFloat(field.Float(), 'g', -1, 64))
+		default:
+			return nil, fmt.Errorf("unsupported callback field type %s", field.Type())
+		}
+	}
+	return fields, nil
+}
+
+func decodeCallbackFields(fields []string, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("callback payload must be a pointer to a struct, not %T", v)
+	}
+	value = value.Elem()
+	n := 0
+	for i := 0; i < value.NumField(); i++ {
+		if !value.Type().Field(i).IsExported() {
+			continue
+		}
+		if n >= len(fields) {
+			return ErrInvalidCallbackData
+		}
+		field, s := value.Field(i), fields[n]
+		n++
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(s)
+		case reflect.Bool:
+			field.SetBool(s != "")
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			x, err := strconv.ParseInt(s, 36, field.Type().Bits())
+			if err != nil {
+				return ErrInvalidCallbackData
+			}
+			field.SetInt(x)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			x, err := strconv.ParseUint(s, 36, field.Type().Bits())
+			if err != nil {
+				return ErrInvalidCallbackData
+			}
+			field.SetUint(x)
+		case reflect.Float32, reflect.Float64:
+			x, err := strconv.ParseFloat(s, field.Type().Bits())
+			if err != nil {
+				return ErrInvalidCallbackData
+			}
+			field.SetFloat(x)
+		default:
+			return fmt.Errorf("unsupported callback field type %s", field.Type())
+		}
+	}
+	if n != len(fields) {
+		return ErrInvalidCallbackData
+	}
+	return nil
+}
+
+// Join fields with “|”, escaping “|” and “\” with a backslash.
+func joinCallbackFields(fields []string) string {
+	escaped := make([]string, len(fields))
+	for i, field := range fields {
+		field = strings.Replace(field, `\`, `\\`, -1)
+		escaped[i] = strings.Replace(field, "|", `\|`, -1)
+	}
+	return strings.Join(escaped, "|")
+}
+
+func splitCallbackFields(data string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+			if i == len(data) {
+				return nil, ErrInvalidCallbackData
+			}
+			field.WriteByte(data[i])
+		case '|':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(data[i])
+		}
+	}
+	return append(fields, field.String()), nil
+}
+
+// NewMemoryCallbackStore returns a store keeping callback data for ttl.
+func NewMemoryCallbackStore(ttl time.Duration) *MemoryCallbackStore {
+	return &MemoryCallbackStore{
+		ttl:     ttl,
+		entries: make(map[string]callbackEntry),
+		swept:   time.Now(),
+	}
+}
+
+// PutCallbackData keeps the data under a new random identifier. The expired
+// entries are dropped once per ttl.
+func (s *MemoryCallbackStore) PutCallbackData(data string) (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if now.Sub(s.swept) >= s.ttl {
+		for key, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, key)
+			}
+		}
+		s.swept = now
+	}
+	s.entries[id] = callbackEntry{data: data, expires: now.Add(s.ttl)}
+	return id, nil
+}
+
+// GetCallbackData returns the data kept under the identifier, or
+// ErrCallbackDataExpired if it is unknown or older than ttl.
+func (s *MemoryCallbackStore) GetCallbackData(id string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry, ok := s.entries[id]
+	if !ok || time.Now().After(entry.expires) {
+		return "", ErrCallbackDataExpired
+	}
+	return entry.data, nil
+}
@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPayload struct {
+	Name   string
+	Page   int
+	Count  uint16
+	Ratio  float64
+	Active bool
+	hidden int
+}
+
+func TestCallbackRoundTrip(t *testing.T) {
+	tests := []struct {
+		secret  string
+		payload testPayload
+	}{
+		{"", testPayload{Name: "pizza", Page: -3, Count: 7, Ratio: 0.5, Active: true}},
+		{"secret", testPayload{Name: `a|b\c`, Page: 1 << 40}},
+		{"secret", testPayload{}},
+		// Too long for a button, kept in the store.
+		{"secret", testPayload{Name: strings.Repeat("x", 100), Active: true}},
+	}
+	for _, test := range tests {
+		codec := NewCallbackCodec([]byte(test.secret))
+		data, err := codec.Encode("p", &test.payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > MaxCallbackDataLength {
+			t.Errorf("got data of %d bytes, want at most %d", len(data), MaxCallbackDataLength)
+		}
+		got := testPayload{hidden: 1}
+		prefix, err := codec.Decode(data, &got)
+		if err != nil {
+			t.Fatalf("Decode(%q) failed: %v", data, err)
+		}
+		want := test.payload
+		want.hidden = 1
+		if prefix != "p" || !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q and %+v, want %q and %+v", prefix, got, "p", want)
+		}
+	}
+}
+
+func TestCallbackNilPayload(t *testing.T) {
+	codec := NewCallbackCodec([]byte("secret"))
+	for _, v := range []interface{}{nil, (*testPayload)(nil)} {
+		data, err := codec.Encode("p", v)
+		if err != nil {
+			t.Fatalf("Encode(%#v) failed: %v", v, err)
+		}
+		if prefix, err := codec.Decode(data, nil); err != nil || prefix != "p" {
+			t.Errorf("got %q and %v, want the prefix", prefix, err)
+		}
+	}
+	if _, err := codec.Encode("p", 42); err == nil {
+		t.Error("got no error encoding an int")
+	}
+}
+
+func TestCallbackForged(t *testing.T) {
+	codec := NewCallbackCodec([]byte("secret"))
+	data, err := codec.Encode("p", &testPayload{Name: "a", Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewCallbackCodec([]byte("other")).Encode("p", &testPayload{Name: "a", Page: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := []string{
+		"",
+		"short",
+		// Another payload under the signature.
+		data[:callbackSignatureLength] + "p|a|3||||",
+		// Signed with another secret.
+		other,
+		// Unsigned.
+		data[callbackSignatureLength:],
+	}
+	for _, data := range forged {
+		if _, err := codec.Decode(data, &testPayload{}); err != ErrInvalidCallbackData {
+			t.Errorf("Decode(%q) = %v, want ErrInvalidCallbackData", data, err)
+		}
+	}
+}
+
+func TestCallbackFieldCount(t *testing.T) {
+	codec := NewCallbackCodec(nil)
+	tests := []string{
+		"p|a|1|2|3",
+		"p|a|1|2|3|1|extra",
+		// Not a number.
+		"p|a|!|2|3|1",
+		// Dangling escape.
+		`p|a|1|2|3|1\`,
+	}
+	for _, data := range tests {
+		if _, err := codec.Decode(data, &testPayload{}); err != ErrInvalidCallbackData {
+			t.Errorf("Decode(%q) = %v, want ErrInvalidCallbackData", data, err)
+		}
+	}
+	if _, err := codec.Decode("p|a|1|2|3|1", &testPayload{}); err != nil {
+		t.Errorf("got %v decoding the right number of fields", err)
+	}
+}
+
+func TestCallbackStore(t *testing.T) {
+	codec := NewCallbackCodec([]byte("secret"))
+	store := NewMemoryCallbackStore(time.Hour)
+	codec.SetStore(store)
+	long := &testPayload{Name: strings.Repeat("y", 80)}
+	data, err := codec.Encode("p", long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.entries) != 1 {
+		t.Fatalf("got %d stored entries, want 1", len(store.entries))
+	}
+	got := &testPayload{}
+	if _, err := codec.Decode(data, got); err != nil || got.Name != long.Name {
+		t.Fatalf("got %+v and %v, want the stored payload", got, err)
+	}
+
+	// Expired entries are no longer returned, and dropped on a later put
+	// once the store was not swept for ttl.
+	for id, entry := range store.entries {
+		entry.expires = time.Now().Add(-time.Minute)
+		store.entries[id] = entry
+	}
+	if _, err := codec.Decode(data, got); err != ErrCallbackDataExpired {
+		t.Fatalf("got %v, want ErrCallbackDataExpired", err)
+	}
+	store.swept = time.Now().Add(-2 * time.Hour)
+	if _, err := store.PutCallbackData("other"); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.entries) != 1 {
+		t.Errorf("got %d stored entries, want only the new one", len(store.entries))
+	}
+}
@@ -0,0 +1,352 @@
+// Command tgbot pokes a Telegram bot from the command line.
+//
+// The token of the bot is read from the TELEGRAM_BOT_TOKEN environment
+// variable, and the API URL from TELEGRAM_BOT_API_URL if set.
+//
+// Usage:
+//
+//	tgbot getme
+//	tgbot send -chat ID (-text TEXT | -sticker FILE_ID | -file PATH) [flags]
+//	tgbot updates tail [-json] [-kinds message,callback_query]
+//	tgbot webhook set -url URL [-cert PATH] [flags]
+//	tgbot webhook delete [-drop-pending]
+//	tgbot webhook info
+//	tgbot replay [-timing] [-to URL] FILE
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bot "github.com/magicae/telegram-bot"
+)
+
+const usage = `Usage: tgbot <command> [arguments]
+
+Commands:
+  getme           print the bot user
+  send            send a text, sticker or file to a chat
+  updates tail    long poll and print updates (confirming them)
+  webhook set     set the webhook
+  webhook delete  delete the webhook
+  webhook info    print the webhook status
+  replay          print or post the updates of a recording
+
+The token is read from $TELEGRAM_BOT_TOKEN.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "getme":
+		err = getMe()
+	case "send":
+		err = send(os.Args[2:])
+	case "updates":
+		err = updates(os.Args[2:])
+	case "webhook":
+		err = webhook(os.Args[2:])
+	case "replay":
+		err = replay(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tgbot:", err)
+		os.Exit(1)
+	}
+}
+
+// Bot with the token and API URL of the environment.
+func newBot() (*bot.Bot, error) {
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	if token == "" {
+		return nil, errors.New("TELEGRAM_BOT_TOKEN is not set")
+	}
+	e := bot.NewBot(token)
+	if url := os.Getenv("TELEGRAM_BOT_API_URL"); url != "" {
+		e.SetAPIURL(url)
+	}
+	return e, nil
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func getMe() error {
+	e, err := newBot()
+	if err != nil {
+		return err
+	}
+	me, err := e.GetMe()
+	if err != nil {
+		return err
+	}
+	return printJSON(me)
+}
+
+func send(args []string) error {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	chat := flags.Int64("chat", 0, "identifier of the target chat")
+	text := flags.String("text", "", "text of the message")
+	parseMode := flags.String("parse-mode", "", "parse mode of the text or caption, HTML or MarkdownV2")
+	sticker := flags.String("sticker", "", "file_id or URL of the sticker to send")
+	file := flags.String("file", "", "path of the file to upload as a document")
+	caption := flags.String("caption", "", "caption of the document")
+	silent := flags.Bool("silent", false, "send the message without notification")
+	flags.Parse(args)
+	if *chat == 0 {
+		return errors.New("send: -chat is required")
+	}
+	e, err := newBot()
+	if err != nil {
+		return err
+	}
+	var message *bot.Message
+	switch {
+	case *text != "":
+		var messages []*bot.Message
+		messages, err = e.SendLongMessage(&bot.SendMessageRequest{
+			ChatID:              *chat,
+			Text:                *text,
+			ParseMode:           *parseMode,
+			DisableNotification: *silent,
+		})
+		if len(messages) > 0 {
+			message = messages[len(messages)-1]
+		}
+	case *sticker != "":
+		message, err = e.SendSticker(&bot.SendStickerRequest{
+			ChatID:              *chat,
+			Sticker:             *sticker,
+			DisableNotification: *silent,
+		})
+	case *file != "":
+		var f *os.File
+		f, err = os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		message, err = e.SendDocument(&bot.SendDocumentRequest{
+			ChatID:              *chat,
+			DocumentFile:        &bot.InputFile{Name: filepath.Base(*file), Reader: f},
+			Caption:             *caption,
+			ParseMode:           *parseMode,
+			DisableNotification: *silent,
+		})
+	default:
+		return errors.New("send: one of -text, -sticker or -file is required")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Sent message", message.MessageID)
+	return nil
+}
+
+func updates(args []string) error {
+	if len(args) == 0 || args[0] != "tail" {
+		return errors.New("updates: unknown subcommand, expected tail")
+	}
+	flags := flag.NewFlagSet("updates tail", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print updates as indented JSON")
+	kinds := flags.String("kinds", "", "comma-separated kinds of updates to receive")
+	flags.Parse(args[1:])
+	e, err := newBot()
+	if err != nil {
+		return err
+	}
+	req := &bot.GetUpdatesRequest{Limit: 100, Timeout: 60}
+	if *kinds != "" {
+		req.AllowedUpdates = strings.Split(*kinds, ",")
+	}
+	for {
+		updates, err := e.GetUpdates(req)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "tgbot:", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for i := range updates {
+			update := &updates[i]
+			if *asJSON {
+				printJSON(update)
+			} else {
+				fmt.Println(summary(update))
+			}
+			if req.Offset <= update.UpdateID {
+				req.Offset = update.UpdateID + 1
+			}
+		}
+	}
+}
+
+// One line describing the update.
+func summary(update *bot.Update) string {
+	c := &bot.Context{Update: update}
+	line := "#" + strconv.Itoa(update.UpdateID) + " " + update.Kind()
+	if chat := c.Chat(); chat != nil {
+		line += " chat=" + strconv.FormatInt(chat.ID, 10)
+		if chat.Title != "" {
+			line += " (" + chat.Title + ")"
+		}
+	}
+	if user := c.Sender(); user != nil {
+		line += " from=" + strconv.Itoa(user.ID)
+		if user.Username != "" {
+			line += " (@" + user.Username + ")"
+		}
+	}
+	if text := c.Text(); text != "" {
+		line += ": " + strconv.Quote(text)
+	}
+	return line
+}
+
+func webhook(args []string) error {
+	if len(args) == 0 {
+		return errors.New("webhook: expected set, delete or info")
+	}
+	e, err := newBot()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "set":
+		flags := flag.NewFlagSet("webhook set", flag.ExitOnError)
+		url := flags.String("url", "", "HTTPS URL to send updates to")
+		cert := flags.String("cert", "", "path of the public key certificate to upload")
+		maxConnections := flags.Int("max-connections", 0, "maximum number of simultaneous connections, 1-100")
+		kinds := flags.String("kinds", "", "comma-separated kinds of updates to receive")
+		dropPending := flags.Bool("drop-pending", false, "drop all pending updates")
+		secret := flags.String("secret", "", "secret token sent in the X-Telegram-Bot-Api-Secret-Token header")
+		flags.Parse(args[1:])
+		if *url == "" {
+			return errors.New("webhook set: -url is required")
+		}
+		req := &bot.SetWebhookRequest{
+			URL:                *url,
+			MaxConnections:     *maxConnections,
+			DropPendingUpdates: *dropPending,
+			SecretToken:        *secret,
+		}
+		if *kinds != "" {
+			req.AllowedUpdates = strings.Split(*kinds, ",")
+		}
+		if *cert != "" {
+			f, err := os.Open(*cert)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			req.Certificate = &bot.InputFile{Name: filepath.Base(*cert), Reader: f}
+		}
+		if err := e.SetWebhook(req); err != nil {
+			return err
+		}
+		fmt.Println("Webhook set to", *url)
+	case "delete":
+		flags := flag.NewFlagSet("webhook delete", flag.ExitOnError)
+		dropPending := flags.Bool("drop-pending", false, "drop all pending updates")
+		flags.Parse(args[1:])
+		if err := e.DeleteWebhook(&bot.DeleteWebhookRequest{DropPendingUpdates: *dropPending}); err != nil {
+			return err
+		}
+		fmt.Println("Webhook deleted")
+	case "info":
+		info, err := e.GetWebhookInfo()
+		if err != nil {
+			return err
+		}
+		return printJSON(info)
+	default:
+		return errors.New("webhook: expected set, delete or info")
+	}
+	return nil
+}
+
+func replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	timing := flags.Bool("timing", false, "wait between updates as long as when they were recorded")
+	to := flags.String("to", "", "URL of a webhook to post the updates to, instead of printing them")
+	secret := flags.String("secret", "", "secret token of the webhook")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("replay: expected the path of a recording")
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := bot.ReadRecording(f)
+	if err != nil {
+		return err
+	}
+	var last time.Time
+	for _, entry := range entries {
+		if entry.Type != bot.RecordUpdate || entry.Update == nil {
+			continue
+		}
+		if *timing && !last.IsZero() && entry.Time.After(last) {
+			time.Sleep(entry.Time.Sub(last))
+		}
+		last = entry.Time
+		if *to == "" {
+			fmt.Println(summary(entry.Update))
+			continue
+		}
+		if err := post(*to, *secret, entry.Update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Post an update to a webhook like Telegram does.
+func post(url, secret string, update *bot.Update) error {
+	body, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("update %d: webhook answered %s", update.UpdateID, res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"context"
+	"errors"
+)
+
+type (
+	// Context carries an update through the handlers chain, along with the
+	// chat, sender and message it concerns and the values set by the
+	// previous handlers.
+	Context struct {
+		// Bot resolving the update, calling the Bot API with the
+		// context.Context of the update.
+		Bot *Bot
+		// Update being resolved.
+		Update *Update
+
+		ctx     context.Context
+		values  map[string]interface{}
+		session *Session
+	}
+
+	// UpdateHandlerFunc is the former signature of handlers, taking the bot
+	// and the update. Use Adapt to register one.
+	UpdateHandlerFunc func(*Bot, *Update) error
+)
+
+var (
+	// ErrNoChat is returned when replying to an update without a chat.
+	ErrNoChat = errors.New("update has no chat")
+	// ErrNoCallbackQuery is returned when answering or editing from an update
+	// which is not a callback query.
+	ErrNoCallbackQuery = errors.New("update is not a callback query")
+	// ErrNoSession is returned when updating the session of an update
+	// without one.
+	ErrNoSession = errors.New("update has no session")
+)
+
+// Adapt turns a handler with the former signature into a HandlerFunc.
+func Adapt(handler UpdateHandlerFunc) HandlerFunc {
+	return func(c *Context) error {
+		return handler(c.Bot, c.Update)
+	}
+}
+
+func newContext(ctx context.Context, e *Bot, update *Update) *Context {
+	return &Context{
+		Bot:    e.WithContext(ctx),
+		Update: update,
+		ctx:    ctx,
+	}
+}
+
+// Context returns the context.Context of the update.
+func (c *Context) Context() context.Context {
+	return c.ctx
+}
+
+// SetContext replaces the context.Context of the update for the next
+// handlers, and of their calls to the Bot API.
+func (c *Context) SetContext(ctx context.Context) {
+	c.ctx = ctx
+	c.Bot = c.Bot.WithContext(ctx)
+}
+
+// Chat returns the chat the update belongs to, or nil.
+func (c *Context) Chat() *Chat {
+	return updateChat(c.Update)
+}
+
+// Sender returns the user who caused the update, or nil.
+func (c *Context) Sender() *User {
+	return updateUser(c.Update)
+}
+
+// Message returns the message the update is about: the new or edited
+// message or channel post, or the message of the callback query. Returns nil
+// for other updates.
+func (c *Context) Message() *Message {
+	update := c.Update
+	switch {
+	case update.Message != nil:
+		return update.Message
+	case update.EditedMessage != nil:
+		return update.EditedMessage
+	case update.ChannelPost != nil:
+		return update.ChannelPost
+	case update.EditedChannelPost != nil:
+		return update.EditedChannelPost
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.Message
+	}
+	return nil
+}
+
+// Text returns the text of the update: the text or caption of the message,
+// the data of the callback query or the query of the inline query.
+func (c *Context) Text() string {
+	update := c.Update
+	switch {
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.Data
+	case update.InlineQuery != nil:
+		return update.InlineQuery.Query
+	}
+	if message := c.Message(); message != nil {
+		if message.Text != "" {
+			return message.Text
+		}
+		return message.Caption
+	}
+	return ""
+}
+
+// Session returns the session of the update, loaded before the handlers
+// chain runs, or nil if sessions are not enabled or the update has no
+// session.
+func (c *Context) Session() *Session {
+	return c.session
+}
+
+// SaveSession saves the session now instead of after the handlers chain. If
+// the session was saved concurrently since it was loaded, the session is
+// reloaded without the changes and ErrSessionConflict is returned: the
+// handler can then apply its changes again and retry, see UpdateSession.
+func (c *Context) SaveSession() error {
+	if c.session == nil || !c.session.modified() {
+		return nil
+	}
+	err := c.Bot.sessionStore.SaveSession(c.session)
+	if err != ErrSessionConflict {
+		return err
+	}
+	session, loadErr := c.Bot.sessionStore.LoadSession(c.session.Key)
+	if loadErr != nil {
+		return loadErr
+	}
+	c.session = session
+	return err
+}
+
+// UpdateSession calls fn with the session and saves it right away. On a
+// concurrent save, fn is called again with the reloaded session, up to a few
+// times before giving up with ErrSessionConflict. Changes computed from the
+// values of the session, e.g. counters, should be made this way so that
+// none is lost.
+func (c *Context) UpdateSession(fn func(session *Session) error) error {
+	if c.session == nil {
+		return ErrNoSession
+	}
+	var err error
+	for attempt := 0; attempt <= sessionRetries; attempt++ {
+		if err = fn(c.session); err != nil {
+			return err
+		}
+		if err = c.SaveSession(); err != ErrSessionConflict {
+			return err
+		}
+	}
+	return err
+}
+
+// Set stores a value for the next handlers, usually from a middleware.
+func (c *Context) Set(key string, value interface{}) {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+// Get returns the value stored with the key, or nil.
+func (c *Context) Get(key string) interface{} {
+	return c.values[key]
+}
+
+// Reply sends a text message to the chat of the update, as a reply to its
+// message if there is one.
+func (c *Context) Reply(text string) (*Message, error) {
+	chat := c.Chat()
+	if chat == nil {
+		return nil, ErrNoChat
+	}
+	body := &SendMessageRequest{
+		ChatID: chat.ID,
+		Text:   text,
+	}
+	if c.Update.CallbackQuery == nil {
+		if message := c.Message(); message != nil {
+			body.ReplyToMessageID = message.MessageID
+		}
+	}
+	return c.Bot.SendMessage(body)
+}
+
+// Answer answers the callback query of the update with a notification.
+func (c *Context) Answer(text string) error {
+	query := c.Update.CallbackQuery
+	if query == nil {
+		return ErrNoCallbackQuery
+	}
+	return c.Bot.AnswerCallbackQuery(&AnswerCallbackQueryRequest{
+		CallbackQueryID: query.ID,
+		Text:            text,
+	})
+}
+
+// Edit replaces the text of the message carrying the inline keyboard the
+// callback query of the update originates from.
+func (c *Context) Edit(text string) (*Message, error) {
+	query := c.Update.CallbackQuery
+	if query == nil {
+		return nil, ErrNoCallbackQuery
+	}
+	body := &EditMessageTextRequest{
+		InlineMessageID: query.InlineMessageID,
+		Text:            text,
+	}
+	if query.Message != nil {
+		body.ChatID = query.Message.Chat.ID
+		body.MessageID = query.Message.MessageID
+	}
+	return c.Bot.EditMessageText(body)
+}
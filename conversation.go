@@ -0,0 +1,287 @@
+package bot
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConversationEnd is returned by the transitions of a step to end the
+// conversation.
+const ConversationEnd = "\x00end"
+
+type (
+	// Conversation is a multi-step dialog with one user in one chat. Each
+	// step is a state of a finite state machine, with an entry prompt and
+	// transitions on the text or callback input of the user. Register it in
+	// the handlers chain with Handler; the updates it consumes do not reach
+	// the handlers after it.
+	Conversation struct {
+		// Name of the conversation, unique among the conversations sharing a
+		// storage.
+		Name string
+		// Commands starting the conversation, e.g. “/signup”.
+		Commands []string
+		// Name of the first step.
+		Start string
+		// Steps of the conversation by name.
+		Steps map[string]*Step
+		// Optional. Commands cancelling the conversation. Defaults to
+		// “/cancel”.
+		CancelCommands []string
+		// Optional. Called when the user cancels the conversation.
+		OnCancel StepFunc
+		// Optional. Duration of inactivity after which the conversation is
+		// dropped. Zero means never.
+		Timeout time.Duration
+		// Optional. Called with the expired state when the user comes back
+		// after a timeout.
+		OnTimeout StepFunc
+		// Optional. Storage of the states. Defaults to an in-memory storage.
+		Storage ConversationStorage
+	}
+
+	// Step is a state of a conversation.
+	Step struct {
+		// Optional. Called when the conversation enters the step, usually to
+		// prompt the user for input.
+		Enter StepFunc
+		// Optional. Called with the text of incoming messages.
+		OnText TransitionFunc
+		// Optional. Called with the data of incoming callback queries.
+		OnCallback TransitionFunc
+	}
+
+	// StepFunc is called on the entry, cancel or timeout of a step.
+	StepFunc func(c *Context, state *ConversationState) error
+
+	// TransitionFunc resolves the input of the user in a step. It returns the
+	// name of the next step, an empty string to stay in the current step or
+	// ConversationEnd to end the conversation.
+	TransitionFunc func(c *Context, state *ConversationState, input string) (string, error)
+
+	// ConversationState is the state of a conversation with one user in one
+	// chat.
+	ConversationState struct {
+		// Name of the current step.
+		Step string `json:"step"`
+		// Values collected along the conversation.
+		Data map[string]string `json:"data"`
+		// Time of the last activity.
+		Updated time.Time `json:"updated"`
+	}
+
+	// ConversationStorage persists the states of conversations.
+	ConversationStorage interface {
+		// GetState returns the state stored with the key, or nil if there is
+		// none.
+		GetState(key string) (*ConversationState, error)
+		// SetState stores the state with the key.
+		SetState(key string, state *ConversationState) error
+		// DeleteState deletes the state stored with the key.
+		DeleteState(key string) error
+	}
+
+	// MemoryConversationStorage keeps the states of conversations in memory.
+	MemoryConversationStorage struct {
+		mutex  sync.Mutex
+		states map[string]*ConversationState
+	}
+)
+
+// ErrUnknownStep is returned when a conversation reaches a step it does not
+// define.
+var ErrUnknownStep = errors.New("unknown conversation step")
+
+// Handler returns the handler running the conversation.
+func (c *Conversation) Handler() HandlerFunc {
+	c.init()
+	return c.handle
+}
+
+func (c *Conversation) init() {
+	if c.Storage == nil {
+		c.Storage = NewMemoryConversationStorage()
+	}
+}
+
+// Begin starts the conversation with the sender of the update, replacing any
+// conversation in progress.
+func (c *Conversation) Begin(ctx *Context) error {
+	c.init()
+	key, ok := c.key(ctx.Update)
+	if !ok {
+		return nil
+	}
+	state := &ConversationState{Data: make(map[string]string)}
+	return c.transition(ctx, key, state, c.Start)
+}
+
+func (c *Conversation) handle(ctx *Context) error {
+	update := ctx.Update
+	key, ok := c.key(update)
+	if !ok {
+		return nil
+	}
+	state, err := c.Storage.GetState(key)
+	if err != nil {
+		return err
+	}
+	if state != nil && c.Timeout > 0 && time.Since(state.Updated) > c.Timeout {
+		if err := c.Storage.DeleteState(key); err != nil {
+			return err
+		}
+		if c.OnTimeout != nil {
+			if err := c.OnTimeout(ctx, state); err != nil {
+				return err
+			}
+		}
+		state = nil
+	}
+	command := updateCommand(update)
+	if state == nil {
+		if command == "" || !hasCommand(c.Commands, command) {
+			return nil
+		}
+		if err := c.Begin(ctx); err != nil {
+			return err
+		}
+		return ErrHandled
+	}
+	cancel := c.CancelCommands
+	if cancel == nil {
+		cancel = []string{"/cancel"}
+	}
+	if command != "" && hasCommand(cancel, command) {
+		if err := c.Storage.DeleteState(key); err != nil {
+			return err
+		}
+		if c.OnCancel != nil {
+			if err := c.OnCancel(ctx, state); err != nil {
+				return err
+			}
+		}
+		return ErrHandled
+	}
+	step := c.Steps[state.Step]
+	if step == nil {
+		return c.dropUnknownStep(key)
+	}
+	var next string
+	switch {
+	case update.Message != nil && step.OnText != nil:
+		next, err = step.OnText(ctx, state, update.Message.Text)
+	case update.CallbackQuery != nil && step.OnCallback != nil:
+		next, err = step.OnCallback(ctx, state, update.CallbackQuery.Data)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.transition(ctx, key, state, next); err != nil {
+		return err
+	}
+	return ErrHandled
+}
+
+// Move the conversation to the next step and persist its state.
+func (c *Conversation) transition(ctx *Context, key string, state *ConversationState, next string) error {
+	if next == ConversationEnd {
+		return c.Storage.DeleteState(key)
+	}
+	state.Updated = time.Now()
+	if next == "" {
+		return c.Storage.SetState(key, state)
+	}
+	step := c.Steps[next]
+	if step == nil {
+		return c.dropUnknownStep(key)
+	}
+	state.Step = next
+	if err := c.Storage.SetState(key, state); err != nil {
+		return err
+	}
+	if step.Enter != nil {
+		return step.Enter(ctx, state)
+	}
+	return nil
+}
+
+// Delete the state of a conversation reaching an unknown step. Returns
+// ErrUnknownStep, or the error of the storage if the state is left behind.
+func (c *Conversation) dropUnknownStep(key string) error {
+	if err := c.Storage.DeleteState(key); err != nil {
+		return err
+	}
+	return ErrUnknownStep
+}
+
+// Key of the state of the conversation with the sender of the update.
+func (c *Conversation) key(update *Update) (string, bool) {
+	chat, user := updateChat(update), updateUser(update)
+	if chat == nil || user == nil {
+		return "", false
+	}
+	return c.Name + ":" + strconv.FormatInt(chat.ID, 10) + ":" +
+		strconv.Itoa(user.ID), true
+}
+
+// Bot command the message of the update starts with, without the bot
+// username, or an empty string.
+func updateCommand(update *Update) string {
+	if update.Message == nil || !strings.HasPrefix(update.Message.Text, "/") {
+		return ""
+	}
+	command := strings.Fields(update.Message.Text)[0]
+	if i := strings.Index(command, "@"); i >= 0 {
+		command = command[:i]
+	}
+	return command
+}
+
+func hasCommand(commands []string, command string) bool {
+	for _, c := range commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+func NewMemoryConversationStorage() *MemoryConversationStorage {
+	return &MemoryConversationStorage{
+		states: make(map[string]*ConversationState),
+	}
+}
+
+func (s *MemoryConversationStorage) GetState(key string) (*ConversationState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state, ok := s.states[key]
+	if !ok {
+		return nil, nil
+	}
+	clone := *state
+	clone.Data = make(map[string]string, len(state.Data))
+	for k, v := range state.Data {
+		clone.Data[k] = v
+	}
+	return &clone, nil
+}
+
+func (s *MemoryConversationStorage) SetState(key string, state *ConversationState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.states[key] = state
+	return nil
+}
+
+func (s *MemoryConversationStorage) DeleteState(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.states, key)
+	return nil
+}
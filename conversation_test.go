@@ -0,0 +1,215 @@
+package bot_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	bot "github.com/magicae/telegram-bot"
+	"github.com/magicae/telegram-bot/bottest"
+)
+
+// Storage failing to delete states.
+type undeletableStorage struct {
+	*bot.MemoryConversationStorage
+}
+
+var errStorage = errors.New("storage failure")
+
+func (s undeletableStorage) DeleteState(key string) error {
+	return errStorage
+}
+
+// Bot running a conversation asking for a name, then an age, and replying
+// to the other messages. Errors of the conversation are appended to errs.
+func newSignup(storage bot.ConversationStorage, errs *[]error) *bot.Bot {
+	signup := &bot.Conversation{
+		Name:     "signup",
+		Commands: []string{"/signup"},
+		Start:    "name",
+		Timeout:  time.Hour,
+		Storage:  storage,
+		Steps: map[string]*bot.Step{
+			"name": {
+				Enter: func(c *bot.Context, state *bot.ConversationState) error {
+					_, err := c.Reply("Name?")
+					return err
+				},
+				OnText: func(c *bot.Context, state *bot.ConversationState, input string) (string, error) {
+					state.Data["name"] = input
+					return "age", nil
+				},
+			},
+			"age": {
+				Enter: func(c *bot.Context, state *bot.ConversationState) error {
+					_, err := c.Reply("Age?")
+					return err
+				},
+				OnText: func(c *bot.Context, state *bot.ConversationState, input string) (string, error) {
+					switch input {
+					case "old":
+						return "retired", nil
+					case "?":
+						_, err := c.Reply("A number please.")
+						return "", err
+					}
+					state.Data["age"] = input
+					_, err := c.Reply(state.Data["name"] + ", " + input + ".")
+					return bot.ConversationEnd, err
+				},
+			},
+		},
+		OnCancel: func(c *bot.Context, state *bot.ConversationState) error {
+			_, err := c.Reply("Cancelled at " + state.Step + ".")
+			return err
+		},
+		OnTimeout: func(c *bot.Context, state *bot.ConversationState) error {
+			_, err := c.Reply("Timed out at " + state.Step + ".")
+			return err
+		},
+	}
+	e := bot.NewBot("")
+	e.SetLogger(nil)
+	handler := signup.Handler()
+	e.AddHandler(func(c *bot.Context) error {
+		err := handler(c)
+		if err != nil && err != bot.ErrHandled {
+			*errs = append(*errs, err)
+		}
+		return err
+	})
+	e.AddHandler(func(c *bot.Context) error {
+		_, err := c.Reply("Unhandled.")
+		return err
+	})
+	return e
+}
+
+func TestConversation(t *testing.T) {
+	alice := bottest.NewUser(1, "Alice")
+	chat := bottest.PrivateChat(alice)
+	tests := []struct {
+		name string
+		// Inputs of the user, and the replies to each of them.
+		inputs  []string
+		replies [][]string
+		// Step stored at the end, or "" if the conversation ended.
+		step string
+	}{
+		{
+			name:    "steps",
+			inputs:  []string{"hello", "/signup", "Alice", "?", "30", "hello"},
+			replies: [][]string{{"Unhandled."}, {"Name?"}, {"Age?"}, {"A number please."}, {"Alice, 30."}, {"Unhandled."}},
+		},
+		{
+			name:    "cancel",
+			inputs:  []string{"/signup", "Alice", "/cancel", "30"},
+			replies: [][]string{{"Name?"}, {"Age?"}, {"Cancelled at age."}, {"Unhandled."}},
+		},
+		{
+			name:    "in progress",
+			inputs:  []string{"/signup", "Alice"},
+			replies: [][]string{{"Name?"}, {"Age?"}},
+			step:    "age",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var errs []error
+			storage := bot.NewMemoryConversationStorage()
+			e := newSignup(storage, &errs)
+			h := bottest.NewHarness(e)
+			defer h.Close()
+			for i, input := range test.inputs {
+				got := bottest.Texts(h.Send(bottest.TextUpdate(alice, chat, input)))
+				if !reflect.DeepEqual(got, test.replies[i]) {
+					t.Fatalf("got %q after %q, want %q", got, input, test.replies[i])
+				}
+			}
+			state, err := storage.GetState("signup:1:1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.step == "" && state != nil || test.step != "" && (state == nil || state.Step != test.step) {
+				t.Errorf("got state %+v, want step %q", state, test.step)
+			}
+			if len(errs) > 0 {
+				t.Errorf("got errors %v", errs)
+			}
+		})
+	}
+}
+
+func TestConversationTimeout(t *testing.T) {
+	alice := bottest.NewUser(1, "Alice")
+	chat := bottest.PrivateChat(alice)
+	var errs []error
+	storage := bot.NewMemoryConversationStorage()
+	e := newSignup(storage, &errs)
+	h := bottest.NewHarness(e)
+	defer h.Close()
+	h.Send(bottest.TextUpdate(alice, chat, "/signup"))
+	h.Send(bottest.TextUpdate(alice, chat, "Alice"))
+
+	state, _ := storage.GetState("signup:1:1")
+	state.Updated = time.Now().Add(-2 * time.Hour)
+	storage.SetState("signup:1:1", state)
+	got := bottest.Texts(h.Send(bottest.TextUpdate(alice, chat, "30")))
+	want := []string{"Timed out at age.", "Unhandled."}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if state, _ := storage.GetState("signup:1:1"); state != nil {
+		t.Errorf("got state %+v after the timeout, want none", state)
+	}
+	if len(errs) > 0 {
+		t.Errorf("got errors %v", errs)
+	}
+}
+
+func TestConversationStorageErrors(t *testing.T) {
+	alice := bottest.NewUser(1, "Alice")
+	chat := bottest.PrivateChat(alice)
+	tests := []struct {
+		name   string
+		inputs []string
+	}{
+		{"end", []string{"/signup", "Alice", "30"}},
+		{"cancel", []string{"/signup", "/cancel"}},
+		{"unknown step", []string{"/signup", "Alice", "old"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var errs []error
+			e := newSignup(undeletableStorage{bot.NewMemoryConversationStorage()}, &errs)
+			h := bottest.NewHarness(e)
+			defer h.Close()
+			for _, input := range test.inputs {
+				h.Send(bottest.TextUpdate(alice, chat, input))
+			}
+			if len(errs) != 1 || errs[0] != errStorage {
+				t.Errorf("got errors %v, want the storage failure", errs)
+			}
+		})
+	}
+}
+
+func TestConversationUnknownStep(t *testing.T) {
+	alice := bottest.NewUser(1, "Alice")
+	chat := bottest.PrivateChat(alice)
+	var errs []error
+	storage := bot.NewMemoryConversationStorage()
+	e := newSignup(storage, &errs)
+	h := bottest.NewHarness(e)
+	defer h.Close()
+	for _, input := range []string{"/signup", "Alice", "old"} {
+		h.Send(bottest.TextUpdate(alice, chat, input))
+	}
+	if len(errs) != 1 || errs[0] != bot.ErrUnknownStep {
+		t.Errorf("got errors %v, want ErrUnknownStep", errs)
+	}
+	if state, _ := storage.GetState("signup:1:1"); state != nil {
+		t.Errorf("got state %+v, want it dropped", state)
+	}
+}
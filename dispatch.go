@@ -0,0 +1,142 @@
+package bot
+
+// Concurrent dispatching of updates. Updates are spread over a fixed number
+// of workers by chat, so that updates from the same chat (or the same user,
+// for updates that do not belong to a chat) are always resolved in order by
+// the same worker.
+type dispatcher struct {
+	bot    *Bot
+	queues []chan dispatchJob
+}
+
+type dispatchJob struct {
+	update *Update
+	done   func()
+}
+
+// SetWorkers makes the bot resolve updates with n concurrent workers, each
+// one queueing up to queueSize updates. When the queue of a worker is full,
+// polling pauses until the worker catches up. A value of n less than 2 keeps
+// the default behaviour of resolving updates one by one.
+func (e *Bot) SetWorkers(n, queueSize int) {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	e.workers = n
+	e.queueSize = queueSize
+}
+
+func (e *Bot) newDispatcher() *dispatcher {
+	if e.workers < 2 {
+		return nil
+	}
+	d := &dispatcher{
+		bot:    e,
+		queues: make([]chan dispatchJob, e.workers),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan dispatchJob, e.queueSize)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+func (d *dispatcher) work(queue chan dispatchJob) {
+	for job := range queue {
+		d.observeQueueDepth()
+		d.bot.handle(job.update)
+		job.done()
+	}
+}
+
+// Queue an update to the worker owning its chat. Blocks while the queue of
+// that worker is full. done is called once the update has been resolved.
+func (d *dispatcher) dispatch(update *Update, done func()) {
+	key := uint64(updateKey(update))
+	d.queues[key%uint64(len(d.queues))] <- dispatchJob{update, done}
+	d.observeQueueDepth()
+}
+
+// Report the number of updates waiting in the queues of the workers.
+func (d *dispatcher) observeQueueDepth() {
+	if d.bot.metrics == nil {
+		return
+	}
+	depth := 0
+	for _, queue := range d.queues {
+		depth += len(queue)
+	}
+	d.bot.metrics.ObserveQueueDepth(depth)
+}
+
+// Key used to keep updates in order: the chat ID if the update belongs to a
+// chat, the user ID otherwise.
+func updateKey(update *Update) int64 {
+	if chat := updateChat(update); chat != nil {
+		return chat.ID
+	}
+	if user := updateUser(update); user != nil {
+		return int64(user.ID)
+	}
+	return int64(update.UpdateID)
+}
+
+// Chat the update belongs to, if any.
+func updateChat(update *Update) *Chat {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat
+	case update.EditedMessage != nil:
+		return update.EditedMessage.Chat
+	case update.ChannelPost != nil:
+		return update.ChannelPost.Chat
+	case update.EditedChannelPost != nil:
+		return update.EditedChannelPost.Chat
+	case update.MessageReaction != nil:
+		return update.MessageReaction.Chat
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat
+	case update.MyChatMember != nil:
+		return update.MyChatMember.Chat
+	case update.ChatMember != nil:
+		return update.ChatMember.Chat
+	case update.ChatJoinRequest != nil:
+		return update.ChatJoinRequest.Chat
+	}
+	return nil
+}
+
+// User who caused the update, if any.
+func updateUser(update *Update) *User {
+	switch {
+	case update.Message != nil:
+		return update.Message.From
+	case update.EditedMessage != nil:
+		return update.EditedMessage.From
+	case update.ChannelPost != nil:
+		return update.ChannelPost.From
+	case update.EditedChannelPost != nil:
+		return update.EditedChannelPost.From
+	case update.MessageReaction != nil:
+		return update.MessageReaction.User
+	case update.InlineQuery != nil:
+		return update.InlineQuery.From
+	case update.ChosenInlineResult != nil:
+		return update.ChosenInlineResult.From
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From
+	case update.ShippingQuery != nil:
+		return update.ShippingQuery.From
+	case update.PreCheckoutQuery != nil:
+		return update.PreCheckoutQuery.From
+	case update.PollAnswer != nil:
+		return update.PollAnswer.User
+	case update.MyChatMember != nil:
+		return update.MyChatMember.From
+	case update.ChatMember != nil:
+		return update.ChatMember.From
+	case update.ChatJoinRequest != nil:
+		return update.ChatJoinRequest.From
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package bot
+
+import "unicode/utf8"
+
+// Types of message entities.
+const (
+	EntityMention     = "mention"
+	EntityHashtag     = "hashtag"
+	EntityCashtag     = "cashtag"
+	EntityBotCommand  = "bot_command"
+	EntityURL         = "url"
+	EntityEmail       = "email"
+	EntityPhoneNumber = "phone_number"
+	EntityBold        = "bold"
+	EntityItalic      = "italic"
+	EntityUnderline   = "underline"
+	EntityStrike      = "strikethrough"
+	EntitySpoiler     = "spoiler"
+	EntityBlockquote  = "blockquote"
+	EntityCode        = "code"
+	EntityPre         = "pre"
+	EntityTextLink    = "text_link"
+	EntityTextMention = "text_mention"
+)
+
+// UTF16Len returns the length of s in UTF-16 code units, the unit of the
+// offsets and lengths of message entities.
+func UTF16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+// UTF16ToByteOffset converts an offset in UTF-16 code units into an offset in
+// bytes in s. An offset in the middle of a surrogate pair is moved back to the
+// start of its character, an offset past the end of s is moved to its end.
+func UTF16ToByteOffset(s string, offset int) int {
+	units := 0
+	for i, r := range s {
+		units += utf16RuneLen(r)
+		if units > offset {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// ByteToUTF16Offset converts an offset in bytes in s into an offset in UTF-16
+// code units. An offset in the middle of a UTF-8 sequence is moved back to the
+// start of its character, an offset past the end of s is moved to its end.
+func ByteToUTF16Offset(s string, offset int) int {
+	if offset > len(s) {
+		offset = len(s)
+	}
+	units := 0
+	for i, r := range s {
+		if i >= offset {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		if i+size > offset {
+			break
+		}
+		units += utf16RuneLen(r)
+	}
+	return units
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// EntityText returns the part of text covered by the entity.
+func EntityText(text string, entity MessageEntity) string {
+	start := UTF16ToByteOffset(text, entity.Offset)
+	end := start + UTF16ToByteOffset(text[start:], entity.Length)
+	return text[start:end]
+}
+
+// EntityText returns the part of the text of the message covered by the
+// entity.
+func (m *Message) EntityText(entity MessageEntity) string {
+	return EntityText(m.Text, entity)
+}
+
+// EntitiesOfType returns the entities of the message of the given types, in
+// order.
+func (m *Message) EntitiesOfType(types ...string) []MessageEntity {
+	var entities []MessageEntity
+	for _, entity := range m.Entities {
+		for _, t := range types {
+			if entity.Type == t {
+				entities = append(entities, entity)
+				break
+			}
+		}
+	}
+	return entities
+}
+
+// Mentions returns the @usernames mentioned in the message.
+func (m *Message) Mentions() []string {
+	return m.entityTexts(EntityMention)
+}
+
+// Hashtags returns the #hashtags of the message.
+func (m *Message) Hashtags() []string {
+	return m.entityTexts(EntityHashtag)
+}
+
+// URLs returns the URLs of the message, either written in the text or behind
+// text links.
+func (m *Message) URLs() []string {
+	var urls []string
+	for _, entity := range m.EntitiesOfType(EntityURL, EntityTextLink) {
+		if entity.Type == EntityTextLink {
+			urls = append(urls, entity.URL)
+		} else {
+			urls = append(urls, m.EntityText(entity))
+		}
+	}
+	return urls
+}
+
+// Commands returns the /commands of the message, including the bot username
+// if present.
+func (m *Message) Commands() []string {
+	return m.entityTexts(EntityBotCommand)
+}
+
+func (m *Message) entityTexts(t string) []string {
+	var texts []string
+	for _, entity := range m.EntitiesOfType(t) {
+		texts = append(texts, m.EntityText(entity))
+	}
+	return texts
+}
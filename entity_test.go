@@ -0,0 +1,119 @@
+package bot
+
+import "testing"
+
+const family = "👨‍👩‍👧"
+
+func TestUTF16Len(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"héllo", 5},
+		{"😀", 2},
+		{"a😀b", 4},
+		{"日本語", 3},
+		{family, 8},
+		{"a\xffb", 3},
+		{"\xe2\x82", 2},
+	}
+	for _, test := range tests {
+		if got := UTF16Len(test.s); got != test.want {
+			t.Errorf("UTF16Len(%q) = %d, want %d", test.s, got, test.want)
+		}
+	}
+}
+
+func TestUTF16ToByteOffset(t *testing.T) {
+	tests := []struct {
+		s      string
+		offset int
+		want   int
+	}{
+		{"hello", 0, 0},
+		{"hello", 3, 3},
+		{"héllo", 2, 3},
+		{"a😀b", 1, 1},
+		// In the middle of the surrogate pair.
+		{"a😀b", 2, 1},
+		{"a😀b", 3, 5},
+		{"a😀b", 4, 6},
+		{"日本語", 1, 3},
+		{"日本語", 2, 6},
+		{family, 2, 4},
+		{family, 3, 7},
+		{family, 4, 7},
+		{family, 8, 18},
+		// Past the end.
+		{"a😀b", 10, 6},
+		{"", 1, 0},
+		{"a\xffb", 2, 2},
+	}
+	for _, test := range tests {
+		if got := UTF16ToByteOffset(test.s, test.offset); got != test.want {
+			t.Errorf("UTF16ToByteOffset(%q, %d) = %d, want %d", test.s, test.offset, got, test.want)
+		}
+	}
+}
+
+func TestByteToUTF16Offset(t *testing.T) {
+	tests := []struct {
+		s      string
+		offset int
+		want   int
+	}{
+		{"hello", 0, 0},
+		{"hello", 3, 3},
+		{"héllo", 3, 2},
+		// In the middle of a UTF-8 sequence.
+		{"héllo", 2, 1},
+		{"a😀b", 1, 1},
+		{"a😀b", 2, 1},
+		{"a😀b", 4, 1},
+		{"a😀b", 5, 3},
+		{"a😀b", 6, 4},
+		{"日本語", 3, 1},
+		{"日本語", 4, 1},
+		{"日本語", 9, 3},
+		{family, 7, 3},
+		{family, 9, 3},
+		{family, 11, 5},
+		// Past the end.
+		{"a😀b", 99, 4},
+		{"a\xffb", 2, 2},
+		{"\xe2\x82", 1, 1},
+	}
+	for _, test := range tests {
+		if got := ByteToUTF16Offset(test.s, test.offset); got != test.want {
+			t.Errorf("ByteToUTF16Offset(%q, %d) = %d, want %d", test.s, test.offset, got, test.want)
+		}
+	}
+}
+
+func TestEntityText(t *testing.T) {
+	tests := []struct {
+		text           string
+		offset, length int
+		want           string
+	}{
+		{"hello world", 6, 5, "world"},
+		{"hi 😀 @bob", 6, 4, "@bob"},
+		{"日本語 #tag", 4, 4, "#tag"},
+		{"x" + family + "y", 1, 8, family},
+		{"x" + family + "y", 9, 1, "y"},
+		// Starting in the middle of the surrogate pair.
+		{"😀a", 1, 2, "😀"},
+		// Past the end.
+		{"abc", 5, 2, ""},
+		{"abc", 1, 10, "bc"},
+		{"a\xffb", 1, 2, "\xffb"},
+	}
+	for _, test := range tests {
+		entity := MessageEntity{Type: EntityMention, Offset: test.offset, Length: test.length}
+		if got := EntityText(test.text, entity); got != test.want {
+			t.Errorf("EntityText(%q, %d, %d) = %q, want %q", test.text, test.offset, test.length, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package format builds formatted message texts, escaping user input for the
+// parse mode of the message.
+package format
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Parse modes, to be used as the ParseMode of the requests sending the
+// built texts.
+const (
+	HTML       = "HTML"
+	MarkdownV2 = "MarkdownV2"
+)
+
+var (
+	htmlEscaper = strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		`"`, "&quot;",
+	)
+	markdownV2Escaper     = newBackslashEscaper("\\_*[]()~`>#+-=|{}.!")
+	markdownV2CodeEscaper = newBackslashEscaper("\\`")
+	markdownV2URLEscaper  = newBackslashEscaper("\\)")
+)
+
+func newBackslashEscaper(chars string) *strings.Replacer {
+	var pairs []string
+	for _, c := range chars {
+		pairs = append(pairs, string(c), "\\"+string(c))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// EscapeHTML escapes s for the HTML parse mode, in text and in attribute
+// values.
+func EscapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// EscapeMarkdownV2 escapes s for the MarkdownV2 parse mode, outside of code
+// and links.
+func EscapeMarkdownV2(s string) string {
+	return markdownV2Escaper.Replace(s)
+}
+
+// EscapeMarkdownV2Code escapes s for the MarkdownV2 parse mode, inside of
+// inline code and pre blocks.
+func EscapeMarkdownV2Code(s string) string {
+	return markdownV2CodeEscaper.Replace(s)
+}
+
+// EscapeMarkdownV2URL escapes s for the MarkdownV2 parse mode, inside of the
+// URL part of links.
+func EscapeMarkdownV2URL(s string) string {
+	return markdownV2URLEscaper.Replace(s)
+}
+
+// Escape escapes s for the given parse mode. Text of any other mode is
+// returned as is.
+func Escape(mode, s string) string {
+	switch mode {
+	case HTML:
+		return EscapeHTML(s)
+	case MarkdownV2:
+		return EscapeMarkdownV2(s)
+	}
+	return s
+}
+
+// Builder builds a formatted text for one parse mode. Every piece of text
+// given to it is escaped, except for Raw.
+type Builder struct {
+	mode string
+	buf  strings.Builder
+}
+
+// NewBuilder returns a builder for the HTML or MarkdownV2 parse mode. With any
+// other mode, the builder drops the formatting and builds plain text.
+func NewBuilder(mode string) *Builder {
+	return &Builder{mode: mode}
+}
+
+// ParseMode returns the parse mode of the built text.
+func (b *Builder) ParseMode() string {
+	return b.mode
+}
+
+// String returns the built text.
+func (b *Builder) String() string {
+	return b.buf.String()
+}
+
+// Raw appends s as is, which must already be valid for the parse mode.
+func (b *Builder) Raw(s string) *Builder {
+	b.buf.WriteString(s)
+	return b
+}
+
+// Text appends plain text.
+func (b *Builder) Text(s string) *Builder {
+	return b.Raw(Escape(b.mode, s))
+}
+
+// Bold appends bold text.
+func (b *Builder) Bold(s string) *Builder {
+	return b.wrap("<b>", "</b>", "*", "*", s)
+}
+
+// Italic appends italic text.
+func (b *Builder) Italic(s string) *Builder {
+	return b.wrap("<i>", "</i>", "_", "_", s)
+}
+
+// Underline appends underlined text.
+func (b *Builder) Underline(s string) *Builder {
+	return b.wrap("<u>", "</u>", "__", "__", s)
+}
+
+// Strike appends strikethrough text.
+func (b *Builder) Strike(s string) *Builder {
+	return b.wrap("<s>", "</s>", "~", "~", s)
+}
+
+// Spoiler appends text hidden behind a spoiler.
+func (b *Builder) Spoiler(s string) *Builder {
+	return b.wrap("<tg-spoiler>", "</tg-spoiler>", "||", "||", s)
+}
+
+// Code appends inline fixed-width code.
+func (b *Builder) Code(s string) *Builder {
+	switch b.mode {
+	case HTML:
+		return b.Raw("<code>" + EscapeHTML(s) + "</code>")
+	case MarkdownV2:
+		return b.Raw("`" + EscapeMarkdownV2Code(s) + "`")
+	}
+	return b.Raw(s)
+}
+
+// Pre appends a pre-formatted fixed-width code block, highlighted for the
+// programming language if not empty.
+func (b *Builder) Pre(s, language string) *Builder {
+	switch b.mode {
+	case HTML:
+		if language == "" {
+			return b.Raw("<pre>" + EscapeHTML(s) + "</pre>")
+		}
+		return b.Raw(`<pre><code class="language-` + EscapeHTML(language) +
+			`">` + EscapeHTML(s) + "</code></pre>")
+	case MarkdownV2:
+		return b.Raw("```" + EscapeMarkdownV2Code(language) + "\n" +
+			EscapeMarkdownV2Code(s) + "\n```")
+	}
+	return b.Raw(s)
+}
+
+// Link appends text linking to the URL.
+func (b *Builder) Link(s, url string) *Builder {
+	switch b.mode {
+	case HTML:
+		return b.Raw(`<a href="` + EscapeHTML(url) + `">` + EscapeHTML(s) + "</a>")
+	case MarkdownV2:
+		return b.Raw("[" + EscapeMarkdownV2(s) + "](" + EscapeMarkdownV2URL(url) + ")")
+	}
+	return b.Raw(s)
+}
+
+// Mention appends text mentioning the user, which works even for users
+// without a username.
+func (b *Builder) Mention(s string, userID int) *Builder {
+	return b.Link(s, "tg://user?id="+strconv.Itoa(userID))
+}
+
+func (b *Builder) wrap(htmlOpen, htmlClose, mdOpen, mdClose, s string) *Builder {
+	switch b.mode {
+	case HTML:
+		return b.Raw(htmlOpen + EscapeHTML(s) + htmlClose)
+	case MarkdownV2:
+		return b.Raw(mdOpen + EscapeMarkdownV2(s) + mdClose)
+	}
+	return b.Raw(s)
+}
@@ -0,0 +1,3 @@
+module github.com/magicae/telegram-bot
+
+go 1.23
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// Handler of a logger discarding every record.
+type discardHandler struct{}
+
+// SetLogger sets the structured logger of the bot, which defaults to
+// slog.Default(). A nil logger discards all logs.
+//
+// Errors are logged at the error level, with the update_id and chat_id of
+// the update concerned and the error_code of Bot API errors. Every API call
+// is logged at the debug level with its method and duration.
+func (e *Bot) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	e.logger = logger
+}
+
+// Logger returns the logger of the bot, for handlers to log with the same
+// logger.
+func (e *Bot) Logger() *slog.Logger {
+	if e.logger == nil {
+		return slog.Default()
+	}
+	return e.logger
+}
+
+// Log an error, with its code if it comes from the Bot API. The token of
+// the bot is redacted from errors returned by handlers too.
+func (e *Bot) logError(msg string, err error, args ...interface{}) {
+	args = append(args, errorAttrs(e.redact(err))...)
+	e.Logger().Error(msg, args...)
+}
+
+func errorAttrs(err error) []interface{} {
+	args := []interface{}{slog.Any("error", err)}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Code != 0 {
+		args = append(args, slog.Int("error_code", apiErr.Code))
+	}
+	return args
+}
+
+// Attributes identifying an update in logs.
+func updateAttrs(update *Update) []interface{} {
+	args := []interface{}{slog.Int("update_id", update.UpdateID)}
+	if chat := updateChat(update); chat != nil {
+		args = append(args, slog.Int64("chat_id", chat.ID))
+	}
+	return args
+}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
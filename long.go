@@ -0,0 +1,342 @@
+package bot
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is the maximum length of the text of a message, in UTF-16
+// code units after entities parsing.
+const MaxMessageLength = 4096
+
+// A piece of text which can not be split: a character, an HTML tag or
+// character reference, or a Markdown marker. Width is its length once
+// parsed, in UTF-16 code units.
+type textToken struct {
+	raw   string
+	width int
+	// Key of the entity opened or closed by the token: the name of an HTML
+	// tag, or the marker of a Markdown entity.
+	open, close string
+	// For tokens opening an entity, the text closing it.
+	closing string
+}
+
+// Markers of the Markdown entities, longest first.
+var (
+	markdownMarkers   = []string{"```", "*", "_", "`"}
+	markdownV2Markers = []string{"```", "||", "__", "*", "_", "~", "`"}
+)
+
+// SendLongMessage sends a text longer than MaxMessageLength as several
+// messages, splitting it on paragraph, line or word boundaries when possible.
+// HTML tags and character references are never split: tags open at a split
+// point are closed at the end of a message and opened again at the start of
+// the next one. Markdown entities, links included, are closed and opened
+// again in the same way, and so are the entities of the request.
+//
+// The reply markup is only attached to the last message, the reply to
+// message only to the first one. All the sent messages are returned, up to
+// the first error.
+func (e *Bot) SendLongMessage(body *SendMessageRequest) ([]*Message, error) {
+	if body.Text == "" {
+		// Let the API report the error.
+		message, err := e.SendMessage(body)
+		if err != nil {
+			return nil, err
+		}
+		return []*Message{message}, nil
+	}
+	tokens := tokenizeText(body.Text, body.ParseMode)
+	chunks := splitTokens(tokens, MaxMessageLength)
+	var messages []*Message
+	var stack []textToken
+	for i, chunk := range chunks {
+		part := *body
+		if i > 0 {
+			part.ReplyToMessageID = 0
+		}
+		if i < len(chunks)-1 {
+			part.ReplyMarkup = nil
+		}
+		part.Text, stack = joinTokens(tokens[chunk[0]:chunk[1]], stack)
+		if body.Entities != nil {
+			part.Entities = clipEntities(body.Entities,
+				tokensWidth(tokens[:chunk[0]]), tokensWidth(tokens[:chunk[1]]))
+		}
+		message, err := e.SendMessage(&part)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func tokenizeText(text, parseMode string) []textToken {
+	switch parseMode {
+	case "HTML":
+		return tokenizeHTML(text)
+	case "Markdown":
+		return tokenizeMarkdown(text, markdownMarkers)
+	case "MarkdownV2":
+		return tokenizeMarkdown(text, markdownV2Markers)
+	}
+	var tokens []textToken
+	for _, r := range text {
+		raw := string(r)
+		tokens = append(tokens, textToken{raw: raw, width: UTF16Len(raw)})
+	}
+	return tokens
+}
+
+func tokenizeHTML(text string) []textToken {
+	var tokens []textToken
+	for len(text) > 0 {
+		n := 0
+		switch text[0] {
+		case '<':
+			n = strings.IndexByte(text, '>') + 1
+		case '&':
+			n = strings.IndexByte(text, ';') + 1
+			if n > 10 {
+				n = 0
+			}
+		}
+		if n <= 0 {
+			_, n = utf8.DecodeRuneInString(text)
+		}
+		token := textToken{raw: text[:n]}
+		switch {
+		case token.raw[0] == '<' && n > 1:
+			name := strings.TrimPrefix(token.raw[1:n-1], "/")
+			if i := strings.IndexAny(name, " \t\n/"); i >= 0 {
+				name = name[:i]
+			}
+			if token.raw[1] == '/' {
+				token.close = name
+			} else {
+				token.open = name
+				token.closing = "</" + name + ">"
+			}
+		case token.raw[0] == '&' && n > 1:
+			token.width = 1
+		default:
+			token.width = UTF16Len(token.raw)
+		}
+		tokens = append(tokens, token)
+		text = text[n:]
+	}
+	return tokens
+}
+
+// Tokenize a Markdown text with the given entity markers. In code and pre
+// entities, only the closing marker and escaped characters are special. The
+// opening marker of a pre entity includes its language line, and a link is
+// opened by its "[" and closed by its "](url)".
+func tokenizeMarkdown(text string, markers []string) []textToken {
+	var tokens []textToken
+	open := make(map[string]bool)
+	// Marker of the code or pre entity open, if any.
+	code := ""
+	// Position and end of the "](url)" closing the link open, if any.
+	link, linkEnd := -1, -1
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		token := textToken{}
+		switch {
+		case rest[0] == '\\' && len(rest) > 1:
+			_, size := utf8.DecodeRuneInString(rest[1:])
+			token.raw = rest[:1+size]
+			token.width = UTF16Len(token.raw[1:])
+		case i == link:
+			token = textToken{raw: text[i:linkEnd], close: "["}
+			link = -1
+		case code != "":
+			if strings.HasPrefix(rest, code) {
+				token = textToken{raw: code, close: code}
+				code = ""
+			}
+		case link < 0 && (rest[0] == '[' || strings.HasPrefix(rest, "![")):
+			start := strings.IndexByte(rest, '[') + 1
+			if bracket, end := markdownLink(rest, start); bracket > 0 {
+				link, linkEnd = i+bracket, i+end
+				token = textToken{raw: rest[:start], open: "[", closing: text[link:linkEnd]}
+			}
+		default:
+			for _, marker := range markers {
+				if !strings.HasPrefix(rest, marker) {
+					continue
+				}
+				if open[marker] {
+					token = textToken{raw: marker, close: marker}
+					delete(open, marker)
+					break
+				}
+				token = textToken{raw: marker, open: marker, closing: marker}
+				switch marker {
+				case "```":
+					// Keep the language line, so that it is opened again.
+					if end := strings.IndexByte(rest, '\n'); end > 0 && !strings.Contains(rest[len(marker):end], "`") {
+						token.raw = rest[:end+1]
+					}
+					code = marker
+				case "`":
+					code = marker
+				default:
+					open[marker] = true
+				}
+				break
+			}
+		}
+		if token.raw == "" {
+			_, n := utf8.DecodeRuneInString(rest)
+			token = textToken{raw: rest[:n], width: UTF16Len(rest[:n])}
+		}
+		tokens = append(tokens, token)
+		i += len(token.raw)
+	}
+	return tokens
+}
+
+// Position of the "]" ending the text of the Markdown link text starts with,
+// the text of the link starting at start, and the end of its "(url)".
+// Returns -1 if there is no link.
+func markdownLink(text string, start int) (int, int) {
+	bracket := markdownIndex(text, start, ']')
+	if bracket < 0 || !strings.HasPrefix(text[bracket+1:], "(") {
+		return -1, -1
+	}
+	paren := markdownIndex(text, bracket+2, ')')
+	if paren < 0 {
+		return -1, -1
+	}
+	return bracket, paren + 1
+}
+
+// Index of the first c of text from start which is not escaped, or -1.
+func markdownIndex(text string, start int, c byte) int {
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+// Split tokens into ranges not wider than limit, dropping the whitespace at
+// the split points.
+func splitTokens(tokens []textToken, limit int) [][2]int {
+	var chunks [][2]int
+	start := 0
+	for start < len(tokens) {
+		width, end := 0, len(tokens)
+		paragraph, line, space := -1, -1, -1
+		for i := start; i < len(tokens); i++ {
+			switch tokens[i].raw {
+			case "\n":
+				if i+1 < len(tokens) && tokens[i+1].raw == "\n" {
+					paragraph = i
+				}
+				line = i
+			case " ":
+				space = i
+			}
+			if width+tokens[i].width > limit && i > start {
+				switch {
+				case paragraph > start:
+					end = paragraph
+				case line > start:
+					end = line
+				case space > start:
+					end = space
+				default:
+					end = i
+				}
+				break
+			}
+			width += tokens[i].width
+		}
+		chunks = append(chunks, [2]int{start, end})
+		start = end
+		for start < len(tokens) && strings.TrimSpace(tokens[start].raw) == "" {
+			start++
+		}
+	}
+	return chunks
+}
+
+// Join tokens into a text, opening first the entities of the stack and
+// closing the ones left open at the end. Returns the text and the entities
+// left open.
+func joinTokens(tokens []textToken, stack []textToken) (string, []textToken) {
+	var b strings.Builder
+	// Key of the entity of the last token written, if it opens or closes
+	// one.
+	last := ""
+	write := func(raw, key string) {
+		// An italic marker followed by an underline one reads as an
+		// underline marker followed by an italic one: separate them with
+		// \r, which Telegram ignores.
+		if last == "_" && strings.HasPrefix(key, "_") {
+			b.WriteString("\r")
+		}
+		b.WriteString(raw)
+		last = key
+	}
+	for _, entity := range stack {
+		write(entity.raw, entity.open)
+	}
+	stack = append([]textToken(nil), stack...)
+	for _, token := range tokens {
+		write(token.raw, token.open+token.close)
+		switch {
+		case token.open != "":
+			stack = append(stack, token)
+		case token.close != "":
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].open == token.close {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		write(stack[i].closing, stack[i].open)
+	}
+	return b.String(), stack
+}
+
+func tokensWidth(tokens []textToken) int {
+	width := 0
+	for _, token := range tokens {
+		width += token.width
+	}
+	return width
+}
+
+// Entities covering the range [start, end), clipped to it and with offsets
+// relative to start.
+func clipEntities(entities []MessageEntity, start, end int) []MessageEntity {
+	var clipped []MessageEntity
+	for _, entity := range entities {
+		from, to := entity.Offset, entity.Offset+entity.Length
+		if from < start {
+			from = start
+		}
+		if to > end {
+			to = end
+		}
+		if from >= to {
+			continue
+		}
+		entity.Offset = from - start
+		entity.Length = to - from
+		clipped = append(clipped, entity)
+	}
+	return clipped
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeText(t *testing.T) {
+	tests := []struct {
+		text, parseMode string
+		want            []textToken
+	}{
+		{"a😀", "", []textToken{{raw: "a", width: 1}, {raw: "😀", width: 2}}},
+		{"<b>x</b>", "", []textToken{
+			{raw: "<", width: 1}, {raw: "b", width: 1}, {raw: ">", width: 1},
+			{raw: "x", width: 1},
+			{raw: "<", width: 1}, {raw: "/", width: 1}, {raw: "b", width: 1}, {raw: ">", width: 1},
+		}},
+		{`<a href="u">x</a>&amp;`, "HTML", []textToken{
+			{raw: `<a href="u">`, open: "a", closing: "</a>"},
+			{raw: "x", width: 1},
+			{raw: "</a>", close: "a"},
+			{raw: "&amp;", width: 1},
+		}},
+		// Not a tag nor a character reference.
+		{"a < b & c", "HTML", []textToken{
+			{raw: "a", width: 1}, {raw: " ", width: 1}, {raw: "<", width: 1}, {raw: " ", width: 1},
+			{raw: "b", width: 1}, {raw: " ", width: 1}, {raw: "&", width: 1}, {raw: " ", width: 1},
+			{raw: "c", width: 1},
+		}},
+		{`*a\*_b_*`, "MarkdownV2", []textToken{
+			{raw: "*", open: "*", closing: "*"},
+			{raw: "a", width: 1},
+			{raw: `\*`, width: 1},
+			{raw: "_", open: "_", closing: "_"},
+			{raw: "b", width: 1},
+			{raw: "_", close: "_"},
+			{raw: "*", close: "*"},
+		}},
+		{"__u__||s||~x~", "MarkdownV2", []textToken{
+			{raw: "__", open: "__", closing: "__"}, {raw: "u", width: 1}, {raw: "__", close: "__"},
+			{raw: "||", open: "||", closing: "||"}, {raw: "s", width: 1}, {raw: "||", close: "||"},
+			{raw: "~", open: "~", closing: "~"}, {raw: "x", width: 1}, {raw: "~", close: "~"},
+		}},
+		// Markers in code are plain characters.
+		{"`*`", "MarkdownV2", []textToken{
+			{raw: "`", open: "`", closing: "`"}, {raw: "*", width: 1}, {raw: "`", close: "`"},
+		}},
+		// The language line belongs to the opening marker of pre.
+		{"```go\nx```", "MarkdownV2", []textToken{
+			{raw: "```go\n", open: "```", closing: "```"}, {raw: "x", width: 1}, {raw: "```", close: "```"},
+		}},
+		{`[a](http://x/\))`, "MarkdownV2", []textToken{
+			{raw: "[", open: "[", closing: `](http://x/\))`},
+			{raw: "a", width: 1},
+			{raw: `](http://x/\))`, close: "["},
+		}},
+		// Legacy Markdown has no strikethrough.
+		{"~*a*", "Markdown", []textToken{
+			{raw: "~", width: 1}, {raw: "*", open: "*", closing: "*"}, {raw: "a", width: 1}, {raw: "*", close: "*"},
+		}},
+	}
+	for _, test := range tests {
+		if got := tokenizeText(test.text, test.parseMode); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("tokenizeText(%q, %q) =\n%+v, want\n%+v", test.text, test.parseMode, got, test.want)
+		}
+	}
+}
+
+func TestSplitTokens(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"abc", 10, []string{"abc"}},
+		{"abcdef", 3, []string{"abc", "def"}},
+		{"ab cd ef", 5, []string{"ab cd", "ef"}},
+		{"ab\ncd ef", 7, []string{"ab", "cd ef"}},
+		{"ab\n\ncd\nef gh", 10, []string{"ab", "cd\nef gh"}},
+		{"ab cd\nef", 6, []string{"ab cd", "ef"}},
+		{"😀😀😀", 4, []string{"😀😀", "😀"}},
+	}
+	for _, test := range tests {
+		tokens := tokenizeText(test.text, "")
+		var got []string
+		for _, chunk := range splitTokens(tokens, test.limit) {
+			text, _ := joinTokens(tokens[chunk[0]:chunk[1]], nil)
+			got = append(got, text)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitting %q at %d gives %q, want %q", test.text, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestSplitEntities(t *testing.T) {
+	tests := []struct {
+		text, parseMode string
+		limit           int
+		want            []string
+	}{
+		{"<b>ab <i>cd</i> ef</b>", "HTML", 4, []string{"<b>ab</b>", "<b><i>cd</i></b>", "<b>ef</b>"}},
+		{`<a href="u">abc def</a>`, "HTML", 4, []string{`<a href="u">abc</a>`, `<a href="u">def</a>`}},
+		{"*ab _cd_ ef*", "MarkdownV2", 4, []string{"*ab*", "*_cd_*", "*ef*"}},
+		{"||abc def||", "MarkdownV2", 4, []string{"||abc||", "||def||"}},
+		{"[abc def](http://x)", "MarkdownV2", 4, []string{"[abc](http://x)", "[def](http://x)"}},
+		{"```go\nab\ncd```", "MarkdownV2", 3, []string{"```go\nab```", "```go\ncd```"}},
+		{"`ab cd`", "MarkdownV2", 3, []string{"`ab`", "`cd`"}},
+		// Italic in underline, then underline in italic.
+		{"___ab cd_\r__", "MarkdownV2", 3, []string{"___ab_\r__", "___cd_\r__"}},
+		{"_ab __cd ef___", "MarkdownV2", 6, []string{"_ab __cd___", "_\r__ef___"}},
+	}
+	for _, test := range tests {
+		tokens := tokenizeText(test.text, test.parseMode)
+		var got []string
+		var stack []textToken
+		for _, chunk := range splitTokens(tokens, test.limit) {
+			var text string
+			text, stack = joinTokens(tokens[chunk[0]:chunk[1]], stack)
+			got = append(got, text)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitting %q at %d gives %q, want %q", test.text, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestClipEntities(t *testing.T) {
+	entities := []MessageEntity{
+		{Type: EntityBold, Offset: 0, Length: 4},
+		{Type: EntityItalic, Offset: 3, Length: 4},
+		{Type: EntityCode, Offset: 8, Length: 2},
+	}
+	tests := []struct {
+		start, end int
+		want       []MessageEntity
+	}{
+		{0, 10, entities},
+		{0, 3, []MessageEntity{{Type: EntityBold, Offset: 0, Length: 3}}},
+		{3, 8, []MessageEntity{
+			{Type: EntityBold, Offset: 0, Length: 1},
+			{Type: EntityItalic, Offset: 0, Length: 4},
+		}},
+		{7, 9, []MessageEntity{{Type: EntityCode, Offset: 1, Length: 1}}},
+		{10, 20, nil},
+	}
+	for _, test := range tests {
+		if got := clipEntities(entities, test.start, test.end); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("clipEntities(%d, %d) = %+v, want %+v", test.start, test.end, got, test.want)
+		}
+	}
+}
+
+func TestSendLongMessageEmpty(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: message text is empty"}`))
+	}))
+	defer server.Close()
+	e := NewBot("token")
+	e.SetAPIURL(server.URL)
+	e.SetLogger(nil)
+	messages, err := e.SendLongMessage(&SendMessageRequest{ChatID: 1})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 400 || messages != nil {
+		t.Fatalf("got %v and %v, want the error of the API", messages, err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
@@ -0,0 +1,241 @@
+package bot
+
+import "strconv"
+
+type (
+	// MenuItem is one selectable item of a menu.
+	MenuItem struct {
+		// Identifier of the item, passed back on selection. Keep it short, it
+		// is part of the callback data of the button.
+		ID string
+		// Label of the button of the item.
+		Text string
+	}
+
+	// MenuSource provides the items of a menu, one page at a time.
+	MenuSource interface {
+		// MenuItems returns at most limit items starting at offset, and the
+		// total number of items.
+		MenuItems(c *Context, offset, limit int) ([]MenuItem, int, error)
+	}
+
+	// MenuSourceFunc adapts a function to a MenuSource.
+	MenuSourceFunc func(c *Context, offset, limit int) ([]MenuItem, int, error)
+
+	// MenuItems is a MenuSource of a fixed list of items.
+	MenuItems []MenuItem
+
+	// Menu is an inline keyboard listing items page by page, with buttons to
+	// go to the previous and next pages. Navigation is handled by the menu
+	// itself, by editing the keyboard of the message; selections are handed
+	// to the select handler. The callbacks go through a CallbackCodec, whose
+	// handler must be in the handlers chain.
+	Menu struct {
+		// Number of items per page. Defaults to 8.
+		PageSize int
+		// Number of item buttons per row. Defaults to 1.
+		Columns int
+		// Labels of the navigation buttons. Default to “‹” and “›”.
+		PrevText, NextText string
+
+		id       string
+		codec    *CallbackCodec
+		source   MenuSource
+		onSelect func(c *Context, item MenuItem) error
+	}
+
+	// Callback payload of the buttons of a menu.
+	menuPayload struct {
+		Action string
+		Page   int
+		Item   string
+	}
+)
+
+// Actions of the buttons of a menu.
+const (
+	menuNoop   = ""
+	menuPage   = "p"
+	menuSelect = "s"
+)
+
+func (f MenuSourceFunc) MenuItems(c *Context, offset, limit int) ([]MenuItem, int, error) {
+	return f(c, offset, limit)
+}
+
+func (items MenuItems) MenuItems(c *Context, offset, limit int) ([]MenuItem, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	if end < offset {
+		end = offset
+	}
+	return items[offset:end], len(items), nil
+}
+
+// NewMenu returns a menu of the items of source, which calls onSelect when
+// an item is selected. The id, unique among the prefixes of the codec,
+// identifies the callbacks of the menu.
+func NewMenu(id string, codec *CallbackCodec, source MenuSource, onSelect func(c *Context, item MenuItem) error) *Menu {
+	m := &Menu{
+		PageSize: 8,
+		Columns:  1,
+		PrevText: "‹",
+		NextText: "›",
+		id:       id,
+		codec:    codec,
+		source:   source,
+		onSelect: onSelect,
+	}
+	HandleCallback(codec, id, m.handle)
+	return m
+}
+
+// Send sends a message with the text and the first page of the menu to the
+// chat of the update.
+func (m *Menu) Send(c *Context, text string) (*Message, error) {
+	chat := c.Chat()
+	if chat == nil {
+		return nil, ErrNoChat
+	}
+	markup, err := m.Markup(c, 0)
+	if err != nil {
+		return nil, err
+	}
+	return c.Bot.SendMessage(&SendMessageRequest{
+		ChatID:      chat.ID,
+		Text:        text,
+		ReplyMarkup: markup,
+	})
+}
+
+// Markup returns the inline keyboard of a page of the menu, counted from 0.
+// A page past the last one, e.g. after items were removed, shows the last
+// page.
+func (m *Menu) Markup(c *Context, page int) (*InlineKeyboardMarkup, error) {
+	size := m.pageSize()
+	if page < 0 {
+		page = 0
+	}
+	items, total, err := m.source.MenuItems(c, page*size, size)
+	if err != nil {
+		return nil, err
+	}
+	if last := (total+size-1)/size - 1; last >= 0 && page > last {
+		page = last
+		items, total, err = m.source.MenuItems(c, page*size, size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	markup := &InlineKeyboardMarkup{}
+	var row []*InlineKeyboardButton
+	for _, item := range items {
+		button, err := m.button(item.Text, menuPayload{menuSelect, page, item.ID})
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, button)
+		if len(row) >= m.Columns {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+			row = nil
+		}
+	}
+	if row != nil {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+	}
+	pages := (total + size - 1) / size
+	if pages <= 1 {
+		return markup, nil
+	}
+	var nav []*InlineKeyboardButton
+	if page > 0 {
+		button, err := m.button(m.PrevText, menuPayload{menuPage, page - 1, ""})
+		if err != nil {
+			return nil, err
+		}
+		nav = append(nav, button)
+	}
+	counter, err := m.button(strconv.Itoa(page+1)+"/"+strconv.Itoa(pages),
+		menuPayload{menuNoop, page, ""})
+	if err != nil {
+		return nil, err
+	}
+	nav = append(nav, counter)
+	if page+1 < pages {
+		button, err := m.button(m.NextText, menuPayload{menuPage, page + 1, ""})
+		if err != nil {
+			return nil, err
+		}
+		nav = append(nav, button)
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, nav)
+	return markup, nil
+}
+
+func (m *Menu) pageSize() int {
+	if m.PageSize < 1 {
+		return 1
+	}
+	return m.PageSize
+}
+
+func (m *Menu) button(text string, payload menuPayload) (*InlineKeyboardButton, error) {
+	data, err := m.codec.Encode(m.id, &payload)
+	if err != nil {
+		return nil, err
+	}
+	return &InlineKeyboardButton{Text: text, CallbackData: data}, nil
+}
+
+func (m *Menu) handle(c *Context, payload *menuPayload) error {
+	query := c.Update.CallbackQuery
+	if payload.Page < 0 {
+		return ErrInvalidCallbackData
+	}
+	switch payload.Action {
+	case menuPage:
+		markup, err := m.Markup(c, payload.Page)
+		if err != nil {
+			return err
+		}
+		body := &EditMessageReplyMarkupRequest{
+			InlineMessageID: query.InlineMessageID,
+			ReplyMarkup:     markup,
+		}
+		if query.Message != nil {
+			body.ChatID = query.Message.Chat.ID
+			body.MessageID = query.Message.MessageID
+		}
+		if _, err := c.Bot.EditMessageReplyMarkup(body); err != nil {
+			return err
+		}
+	case menuSelect:
+		if err := c.Answer(""); err != nil {
+			return err
+		}
+		return m.onSelect(c, m.item(c, payload))
+	}
+	return c.Answer("")
+}
+
+// Item selected from a page, looked up again in the source for its text.
+func (m *Menu) item(c *Context, payload *menuPayload) MenuItem {
+	size := m.pageSize()
+	items, _, err := m.source.MenuItems(c, payload.Page*size, size)
+	if err == nil {
+		for _, item := range items {
+			if item.ID == payload.Item {
+				return item
+			}
+		}
+	}
+	return MenuItem{ID: payload.Item}
+}
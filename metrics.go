@@ -0,0 +1,244 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Metrics receives measurements of the calls to the Bot API and of the
+	// resolution of updates. Implementations must be safe for concurrent use.
+	Metrics interface {
+		// ObserveCall reports a call to the Bot API. err is an *APIError if
+		// the Bot API answered with an error.
+		ObserveCall(method string, duration time.Duration, err error)
+		// ObserveRetry reports that a failed call is about to be retried.
+		ObserveRetry(method string)
+		// ObserveUpdate reports an update resolved by the handlers chain, and
+		// the error terminating it if any.
+		ObserveUpdate(kind string, duration time.Duration, err error)
+		// ObservePanic reports a panic in a handler.
+		ObservePanic(kind string)
+		// ObserveQueueDepth reports the number of updates waiting for a
+		// worker, see SetWorkers.
+		ObserveQueueDepth(depth int)
+	}
+
+	// TextMetrics keeps metrics in memory and serves them over HTTP in the
+	// Prometheus text exposition format.
+	TextMetrics struct {
+		namespace string
+		mutex     sync.Mutex
+		counters  map[string]map[string]float64
+		durations map[string]map[string]*histogram
+		depth     int
+	}
+
+	// Cumulative histogram of durations in seconds.
+	histogram struct {
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+)
+
+// Upper bounds of the duration histograms, in seconds. Long polling calls
+// last up to their timeout, hence the upper buckets.
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Help of the exposed metrics, by name without namespace.
+var metricsHelp = map[string]string{
+	"api_calls_total":           "Calls to the Bot API by method and error code.",
+	"api_call_duration_seconds": "Duration of the calls to the Bot API by method.",
+	"api_retries_total":         "Failed calls to the Bot API retried by method.",
+	"updates_total":             "Updates resolved by kind and result.",
+	"update_duration_seconds":   "Duration of the resolution of updates by kind.",
+	"handler_panics_total":      "Panics in handlers by kind of update.",
+	"dispatch_queue_depth":      "Updates waiting for a worker.",
+}
+
+// SetMetrics sets the metrics the bot reports to.
+func (e *Bot) SetMetrics(metrics Metrics) {
+	e.metrics = metrics
+}
+
+// NewTextMetrics returns metrics named with the given namespace as prefix,
+// e.g. "telegram_bot".
+func NewTextMetrics(namespace string) *TextMetrics {
+	return &TextMetrics{
+		namespace: namespace,
+		counters:  make(map[string]map[string]float64),
+		durations: make(map[string]map[string]*histogram),
+	}
+}
+
+func (m *TextMetrics) ObserveCall(method string, duration time.Duration, err error) {
+	code := "ok"
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		code = strconv.Itoa(apiErr.Code)
+	case err != nil:
+		code = "error"
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.add("api_calls_total", metricLabels("method", method, "code", code))
+	m.observe("api_call_duration_seconds", metricLabels("method", method), duration)
+}
+
+func (m *TextMetrics) ObserveRetry(method string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.add("api_retries_total", metricLabels("method", method))
+}
+
+func (m *TextMetrics) ObserveUpdate(kind string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.add("updates_total", metricLabels("kind", kind, "result", result))
+	m.observe("update_duration_seconds", metricLabels("kind", kind), duration)
+}
+
+func (m *TextMetrics) ObservePanic(kind string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.add("handler_panics_total", metricLabels("kind", kind))
+}
+
+func (m *TextMetrics) ObserveQueueDepth(depth int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.depth = depth
+}
+
+// ServeHTTP writes the metrics in the text exposition format.
+func (m *TextMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the text exposition format to w.
+func (m *TextMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	b := &strings.Builder{}
+	for _, name := range sortedKeys(m.counters) {
+		m.header(b, name, "counter")
+		series := m.counters[name]
+		for _, l := range sortedKeys(series) {
+			fmt.Fprintf(b, "%s%s %s\n", m.name(name), braceLabels(l), formatFloat(series[l]))
+		}
+	}
+	for _, name := range sortedKeys(m.durations) {
+		m.header(b, name, "histogram")
+		series := m.durations[name]
+		for _, l := range sortedKeys(series) {
+			h := series[l]
+			for i, bound := range durationBuckets {
+				le := `le="` + formatFloat(bound) + `"`
+				fmt.Fprintf(b, "%s_bucket%s %d\n", m.name(name), braceLabels(joinLabels(l, le)), h.counts[i])
+			}
+			fmt.Fprintf(b, "%s_bucket%s %d\n", m.name(name), braceLabels(joinLabels(l, `le="+Inf"`)), h.count)
+			fmt.Fprintf(b, "%s_sum%s %s\n", m.name(name), braceLabels(l), formatFloat(h.sum))
+			fmt.Fprintf(b, "%s_count%s %d\n", m.name(name), braceLabels(l), h.count)
+		}
+	}
+	m.header(b, "dispatch_queue_depth", "gauge")
+	fmt.Fprintf(b, "%s %d\n", m.name("dispatch_queue_depth"), m.depth)
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (m *TextMetrics) name(name string) string {
+	if m.namespace == "" {
+		return name
+	}
+	return m.namespace + "_" + name
+}
+
+func (m *TextMetrics) header(b *strings.Builder, name, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", m.name(name), metricsHelp[name])
+	fmt.Fprintf(b, "# TYPE %s %s\n", m.name(name), kind)
+}
+
+func (m *TextMetrics) add(name, labels string) {
+	series := m.counters[name]
+	if series == nil {
+		series = make(map[string]float64)
+		m.counters[name] = series
+	}
+	series[labels]++
+}
+
+func (m *TextMetrics) observe(name, labels string, duration time.Duration) {
+	series := m.durations[name]
+	if series == nil {
+		series = make(map[string]*histogram)
+		m.durations[name] = series
+	}
+	h := series[labels]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		series[labels] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Format label pairs as in the exposition format, without braces.
+func metricLabels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escapeLabel(pairs[i+1])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func joinLabels(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
+
+func braceLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
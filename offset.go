@@ -0,0 +1,226 @@
+package bot
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// OffsetStore persists the long polling offset, i.e. the identifier of the
+	// next update to fetch. The offset saved, and the one the bot polls
+	// with, is the one following the updates whose handlers have all
+	// returned: an update still being resolved is neither saved past nor
+	// confirmed to Telegram, even while later ones are already done.
+	OffsetStore interface {
+		// LoadOffset returns the saved offset, or 0 if there is none.
+		LoadOffset() (int, error)
+		// SaveOffset saves the offset.
+		SaveOffset(offset int) error
+	}
+
+	// MemoryOffsetStore keeps the offset in memory. It is the default store
+	// and does not survive restarts.
+	MemoryOffsetStore struct {
+		mutex  sync.Mutex
+		offset int
+	}
+
+	// FileOffsetStore keeps the offset in a file.
+	FileOffsetStore struct {
+		path string
+	}
+)
+
+// SetOffsetStore sets the store of the long polling offset.
+func (e *Bot) SetOffsetStore(store OffsetStore) {
+	e.offsetStore = store
+}
+
+func NewMemoryOffsetStore() *MemoryOffsetStore {
+	return &MemoryOffsetStore{}
+}
+
+func (s *MemoryOffsetStore) LoadOffset() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.offset, nil
+}
+
+func (s *MemoryOffsetStore) SaveOffset(offset int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.offset = offset
+	return nil
+}
+
+func NewFileOffsetStore(path string) *FileOffsetStore {
+	return &FileOffsetStore{path: path}
+}
+
+func (s *FileOffsetStore) LoadOffset() (int, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// SaveOffset writes the offset to a temporary file and renames it over the
+// store file, so a crash can not leave a truncated offset behind.
+func (s *FileOffsetStore) SaveOffset(offset int) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(strconv.Itoa(offset) + "\n")
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Delay between polls while only unfinished updates are received.
+const pollInterval = time.Second
+
+// Tracks the updates being resolved, so that neither the offset polled with
+// nor the one saved to the store ever skips an unfinished update: Telegram
+// deletes the updates below the offset of getUpdates, so they would be lost
+// on a crash. The offset is saved in the background, only ever moving
+// forward.
+type offsetTracker struct {
+	store   OffsetStore
+	bot     *Bot
+	mutex   sync.Mutex
+	pending map[int]bool
+	// Updates at or above the committed offset already resolved, received
+	// again until the offset moves past them.
+	done map[int]bool
+	// Offset following the last update started.
+	next int
+	// Highest offset below which all updates have finished, and the last
+	// one saved.
+	committed int
+	saved     int
+	commits   chan struct{}
+	// Signalled whenever an update is resolved.
+	finished chan struct{}
+}
+
+func newOffsetTracker(e *Bot, store OffsetStore, offset int) *offsetTracker {
+	t := &offsetTracker{
+		store:     store,
+		bot:       e,
+		pending:   make(map[int]bool),
+		done:      make(map[int]bool),
+		next:      offset,
+		committed: offset,
+		saved:     offset,
+		commits:   make(chan struct{}, 1),
+		finished:  make(chan struct{}, 1),
+	}
+	go t.save()
+	return t
+}
+
+// Offset to poll with: the lowest unfinished update, or the one following
+// the last update if all are finished.
+func (t *offsetTracker) offset() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.committed
+}
+
+// Mark an update as being resolved. Returns false if the update was already
+// received, i.e. it is pending, resolved or below the committed offset.
+func (t *offsetTracker) start(id int) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if id < t.committed || t.pending[id] || t.done[id] {
+		return false
+	}
+	t.pending[id] = true
+	if t.next < id+1 {
+		t.next = id + 1
+	}
+	return true
+}
+
+// Mark an update as resolved, committing the offset if every earlier update
+// is resolved too.
+func (t *offsetTracker) finish(id int) {
+	t.mutex.Lock()
+	delete(t.pending, id)
+	t.done[id] = true
+	committed := t.next
+	for pending := range t.pending {
+		if pending < committed {
+			committed = pending
+		}
+	}
+	advanced := committed > t.committed
+	if advanced {
+		t.committed = committed
+		for done := range t.done {
+			if done < committed {
+				delete(t.done, done)
+			}
+		}
+	}
+	t.mutex.Unlock()
+	notify(t.finished)
+	if advanced {
+		notify(t.commits)
+	}
+}
+
+// Wait until an update is resolved, or at most timeout.
+func (t *offsetTracker) wait(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-t.finished:
+	case <-timer.C:
+	}
+}
+
+// Save the committed offset whenever it advances. Commits arriving while
+// saving are coalesced into a single save of the latest offset.
+func (t *offsetTracker) save() {
+	for range t.commits {
+		t.mutex.Lock()
+		offset := t.committed
+		t.mutex.Unlock()
+		if offset <= t.saved {
+			continue
+		}
+		if err := t.store.SaveOffset(offset); err != nil {
+			t.bot.logError("save offset", err, slog.Int("offset", offset))
+			continue
+		}
+		t.saved = offset
+	}
+}
+
+// Signal a channel of capacity 1 without blocking.
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Wait for the tracker to save offset to its store.
+func waitSaved(t *testing.T, store OffsetStore, offset int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		saved, err := store.LoadOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved == offset {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got saved offset %d, want %d", saved, offset)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOffsetTrackerOutOfOrder(t *testing.T) {
+	store := NewMemoryOffsetStore()
+	tracker := newOffsetTracker(NewBot("token"), store, 10)
+	for id := 10; id < 14; id++ {
+		if !tracker.start(id) {
+			t.Fatalf("update %d not started", id)
+		}
+	}
+	steps := []struct {
+		finish int
+		want   int
+	}{
+		// Only below the lowest pending update.
+		{12, 10},
+		{11, 10},
+		{10, 13},
+		{13, 14},
+	}
+	for _, step := range steps {
+		tracker.finish(step.finish)
+		if got := tracker.offset(); got != step.want {
+			t.Fatalf("after finishing %d, got offset %d, want %d", step.finish, got, step.want)
+		}
+	}
+	waitSaved(t, store, 14)
+}
+
+func TestOffsetTrackerSkipsReceived(t *testing.T) {
+	tracker := newOffsetTracker(NewBot("token"), NewMemoryOffsetStore(), 1)
+	tracker.start(1)
+	tracker.start(2)
+	tracker.finish(2)
+	// Polling from the pending update 1 receives 1 and 2 again.
+	for _, id := range []int{1, 2} {
+		if tracker.start(id) {
+			t.Errorf("update %d started again", id)
+		}
+	}
+	if !tracker.start(3) {
+		t.Error("update 3 not started")
+	}
+	tracker.finish(1)
+	tracker.finish(3)
+	if got := tracker.offset(); got != 4 {
+		t.Fatalf("got offset %d, want 4", got)
+	}
+	if tracker.start(2) {
+		t.Error("update 2 below the offset started again")
+	}
+	if len(tracker.done) != 0 {
+		t.Errorf("got %d finished updates kept, want none", len(tracker.done))
+	}
+}
+
+func TestOffsetTrackerRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offset")
+	store := NewFileOffsetStore(path)
+	tracker := newOffsetTracker(NewBot("token"), store, 0)
+	for _, id := range []int{5, 6, 7} {
+		tracker.start(id)
+	}
+	tracker.finish(5)
+	tracker.finish(7)
+	waitSaved(t, store, 6)
+
+	// Update 6 was still being resolved: after a restart, polling resumes
+	// from it.
+	offset, err := NewFileOffsetStore(path).LoadOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := newOffsetTracker(NewBot("token"), store, offset)
+	if got := restarted.offset(); got != 6 {
+		t.Fatalf("got offset %d after a restart, want 6", got)
+	}
+	if restarted.start(5) || !restarted.start(6) || !restarted.start(7) {
+		t.Fatal("want only the updates from 6 started after a restart")
+	}
+}
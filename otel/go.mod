@@ -0,0 +1,21 @@
+module github.com/magicae/telegram-bot/otel
+
+go 1.25.0
+
+require (
+	github.com/magicae/telegram-bot v0.1.0
+	go.opentelemetry.io/otel v1.46.0
+	go.opentelemetry.io/otel/trace v1.46.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-logr/logr v1.4.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	go.opentelemetry.io/otel/metric v1.46.0 // indirect
+)
+
+// Build against the bot in the parent directory within the repository.
+// Modules depending on the adapter get the version required above.
+replace github.com/magicae/telegram-bot => ../
@@ -0,0 +1,87 @@
+// Package otel adapts OpenTelemetry tracing to the Tracer interface of the
+// bot, so that updates and calls to the Bot API are traced with it:
+//
+//	e.SetTracer(otel.NewTracer(otel.DefaultTracerProvider()))
+//
+// It is a module of its own, so that the bot does not depend on
+// OpenTelemetry unless the adapter is used.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	bot "github.com/magicae/telegram-bot"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer obtained from the tracer provider.
+const instrumentationName = "github.com/magicae/telegram-bot"
+
+type (
+	// Tracer starts OpenTelemetry spans.
+	Tracer struct {
+		tracer trace.Tracer
+	}
+
+	// Span wraps an OpenTelemetry span.
+	Span struct {
+		span trace.Span
+	}
+)
+
+// DefaultTracerProvider returns the global tracer provider of OpenTelemetry.
+func DefaultTracerProvider() trace.TracerProvider {
+	return otel.GetTracerProvider()
+}
+
+// NewTracer returns a tracer starting its spans with a tracer of provider.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(instrumentationName)}
+}
+
+// StartSpan starts a span. The span of an update is a consumer span, the
+// span of a call to the Bot API a client span.
+func (t *Tracer) StartSpan(ctx context.Context, name string) (context.Context, bot.Span) {
+	kind := trace.SpanKindInternal
+	switch {
+	case name == bot.SpanUpdate:
+		kind = trace.SpanKindConsumer
+	case strings.HasPrefix(name, bot.SpanCallPrefix):
+		kind = trace.SpanKindClient
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, &Span{span: span}
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.span.SetAttributes(keyValue(key, value))
+}
+
+func (s *Span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *Span) End() {
+	s.span.End()
+}
+
+func keyValue(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of the entries of a recording.
+const (
+	RecordUpdate = "update"
+	RecordCall   = "call"
+)
+
+type (
+	// RecordEntry is one line of a recording, either a received update or an
+	// API call with its response.
+	RecordEntry struct {
+		// Time of the entry.
+		Time time.Time `json:"time"`
+		// Type of the entry, RecordUpdate or RecordCall.
+		Type string `json:"type"`
+		// For updates, the update.
+		Update *Update `json:"update,omitempty"`
+		// For calls, the name of the method.
+		Method string `json:"method,omitempty"`
+		// For calls, the JSON parameters of the method.
+		Params json.RawMessage `json:"params,omitempty"`
+		// For calls, the JSON response of the API, if any.
+		Response json.RawMessage `json:"response,omitempty"`
+		// For calls, the error of the call, if any.
+		Error string `json:"error,omitempty"`
+	}
+
+	// Recorder appends the updates received by a bot, and optionally its API
+	// calls, to a JSONL stream which can be replayed with Bot.Replay.
+	Recorder struct {
+		mutex   sync.Mutex
+		encoder *json.Encoder
+		calls   bool
+	}
+)
+
+// NewRecorder returns a recorder writing to w, recording the API calls too
+// if calls is true.
+func NewRecorder(w io.Writer, calls bool) *Recorder {
+	return &Recorder{
+		encoder: json.NewEncoder(w),
+		calls:   calls,
+	}
+}
+
+// SetRecorder makes the bot record every update it resolves, and its API
+// calls if enabled, except for getUpdates.
+func (e *Bot) SetRecorder(recorder *Recorder) {
+	e.recorder = recorder
+}
+
+// Record an API call, if the bot has a recorder, with the token of the bot
+// redacted: it may be part of a webhook URL.
+func (e *Bot) recordCall(method string, params, response []byte, err error) {
+	if e.recorder == nil {
+		return
+	}
+	params = []byte(e.redactString(string(params)))
+	response = []byte(e.redactString(string(response)))
+	e.recorder.RecordCall(method, params, response, e.redact(err))
+}
+
+// RecordUpdate appends an update to the recording.
+func (r *Recorder) RecordUpdate(update *Update) error {
+	return r.write(&RecordEntry{
+		Time:   time.Now(),
+		Type:   RecordUpdate,
+		Update: update,
+	})
+}
+
+// RecordCall appends an API call to the recording, if calls are recorded.
+func (r *Recorder) RecordCall(method string, params, response []byte, err error) error {
+	if !r.calls {
+		return nil
+	}
+	entry := &RecordEntry{
+		Time:   time.Now(),
+		Type:   RecordCall,
+		Method: method,
+	}
+	if json.Valid(params) {
+		entry.Params = params
+	}
+	if json.Valid(response) {
+		entry.Response = response
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return r.write(entry)
+}
+
+func (r *Recorder) write(entry *RecordEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.encoder.Encode(entry)
+}
+
+// ReadRecording reads the entries of a recording.
+func ReadRecording(r io.Reader) ([]*RecordEntry, error) {
+	var entries []*RecordEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		entry := &RecordEntry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Replay resolves the updates of a recording with the handlers chain, one by
+// one and in order, sleeping between them as long as when they were recorded
+// if preserveTiming is true.
+//
+// The API calls of the handlers do not reach Telegram: they get the responses
+// recorded for the same method, in order, and an empty successful response
+// once there are none left, so handlers may get nil results. The bot itself
+// is left untouched, and may be running meanwhile.
+func (e *Bot) Replay(r io.Reader, preserveTiming bool) error {
+	entries, err := ReadRecording(r)
+	if err != nil {
+		return err
+	}
+	backend := &replayTransport{responses: make(map[string][][]byte)}
+	for _, entry := range entries {
+		if entry.Type == RecordCall && entry.Response != nil {
+			backend.responses[entry.Method] = append(backend.responses[entry.Method], entry.Response)
+		}
+	}
+	// Replay with a shallow copy of the bot, so that a bot running at the
+	// same time keeps calling Telegram.
+	replay := *e
+	replay.client, replay.recorder = &http.Client{Transport: backend}, nil
+	var last time.Time
+	for _, entry := range entries {
+		if entry.Type != RecordUpdate || entry.Update == nil {
+			continue
+		}
+		if preserveTiming && !last.IsZero() && entry.Time.After(last) {
+			time.Sleep(entry.Time.Sub(last))
+		}
+		last = entry.Time
+		replay.handle(entry.Update)
+	}
+	return nil
+}
+
+// Fake API backend answering calls with recorded responses.
+type replayTransport struct {
+	mutex     sync.Mutex
+	responses map[string][][]byte
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	body := []byte(`{"ok":true}`)
+	t.mutex.Lock()
+	if responses := t.responses[method]; len(responses) > 0 {
+		body, t.responses[method] = responses[0], responses[1:]
+	}
+	t.mutex.Unlock()
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
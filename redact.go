@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Replaces the token of the bot in errors and logs.
+const redactedToken = "<token>"
+
+// Error with the token of the bot redacted from its message. Unwrap gives
+// access to the original error, for errors.Is and errors.As.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Redact the token of the bot from s.
+func (e *Bot) redactString(s string) string {
+	if e.token == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, e.token, redactedToken)
+}
+
+// Redact the token of the bot from err, which the net/http package includes
+// in errors through the URL of the request. A *url.Error is rebuilt with the
+// redacted URL so that callers can still inspect it.
+func (e *Bot) redact(err error) error {
+	if err == nil || e.token == "" || !strings.Contains(err.Error(), e.token) {
+		return err
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr == err {
+		return &url.Error{
+			Op:  urlErr.Op,
+			URL: e.redactString(urlErr.URL),
+			Err: e.redact(urlErr.Err),
+		}
+	}
+	return &redactedError{msg: e.redactString(err.Error()), err: err}
+}
@@ -0,0 +1,143 @@
+package bot_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	bot "github.com/magicae/telegram-bot"
+	"github.com/magicae/telegram-bot/bottest"
+)
+
+const secretToken = "987654:SECRET-token-SECRET"
+
+func TestRedactTransportErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer redirect.Close()
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	tests := []struct {
+		name   string
+		url    string
+		client *http.Client
+	}{
+		{"refused", refused.URL, http.DefaultClient},
+		{"timeout", slow.URL, &http.Client{Timeout: 50 * time.Millisecond}},
+		{"redirect", redirect.URL, http.DefaultClient},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := bot.NewBot(secretToken)
+			e.SetLogger(nil)
+			e.SetAPIURL(test.url)
+			e.SetHTTPClient(test.client)
+			_, err := e.GetMe()
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if strings.Contains(err.Error(), secretToken) {
+				t.Errorf("error contains the token: %v", err)
+			}
+			var urlErr *url.Error
+			if !errors.As(err, &urlErr) {
+				t.Fatalf("got %T, want a *url.Error", err)
+			}
+			if strings.Contains(urlErr.URL, secretToken) {
+				t.Errorf("URL of the error contains the token: %s", urlErr.URL)
+			}
+			if test.name == "timeout" && !urlErr.Timeout() {
+				t.Errorf("got %v, want a timeout", err)
+			}
+		})
+	}
+}
+
+func TestRedactLogs(t *testing.T) {
+	s := bottest.NewServer()
+	defer s.Close()
+	buf := &bytes.Buffer{}
+	e := bot.NewBot(secretToken)
+	e.SetAPIURL(s.URL())
+	e.SetLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if _, err := e.GetMe(); err != nil {
+		t.Fatal(err)
+	}
+
+	e.AddHandler(func(c *bot.Context) error {
+		switch c.Text() {
+		case "error":
+			return fmt.Errorf("calling with %s failed", secretToken)
+		case "panic":
+			panic("token " + secretToken)
+		}
+		return nil
+	})
+	alice := bottest.NewUser(1, "Alice")
+	for _, text := range []string{"error", "panic"} {
+		e.HandleUpdate(bottest.TextUpdate(alice, bottest.PrivateChat(alice), text))
+	}
+
+	// A refused connection too, logged at the debug level.
+	e.SetAPIURL("http://127.0.0.1:1")
+	e.GetMe()
+
+	logs := buf.String()
+	for _, want := range []string{"api call", "handle update", "handler panicked"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs do not contain %q:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, secretToken) {
+		t.Errorf("logs contain the token:\n%s", logs)
+	}
+}
+
+func TestRedactRecording(t *testing.T) {
+	s := bottest.NewServer()
+	defer s.Close()
+	e := bot.NewBot(secretToken)
+	e.SetLogger(nil)
+	e.SetAPIURL(s.URL())
+	buf := &bytes.Buffer{}
+	e.SetRecorder(bot.NewRecorder(buf, true))
+	hook := "https://example.com/" + secretToken
+	if err := e.SetWebhook(&bot.SetWebhookRequest{URL: hook}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.GetWebhookInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := bot.ReadRecording(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Params == nil || entries[1].Response == nil {
+		t.Fatalf("got %d entries, want the setWebhook and getWebhookInfo calls:\n%s", len(entries), buf)
+	}
+	params := &bot.SetWebhookRequest{}
+	if err := json.Unmarshal(entries[0].Params, params); err != nil {
+		t.Fatal(err)
+	}
+	if params.URL != "https://example.com/<token>" {
+		t.Errorf("got URL %q, want it redacted", params.URL)
+	}
+	if strings.Contains(buf.String(), secretToken) {
+		t.Errorf("recording contains the token:\n%s", buf)
+	}
+}
@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/magicae/telegram-bot/format"
+)
+
+// HTML returns the text of the message, or its caption, formatted for the
+// HTML parse mode according to its entities.
+func (m *Message) HTML() string {
+	text, entities := m.formatted()
+	return renderEntities(text, entities, htmlRenderer{})
+}
+
+// MarkdownV2 returns the text of the message, or its caption, formatted for
+// the MarkdownV2 parse mode according to its entities. Block quotations are
+// rendered as plain text.
+func (m *Message) MarkdownV2() string {
+	text, entities := m.formatted()
+	return renderEntities(text, entities, markdownV2Renderer{})
+}
+
+func (m *Message) formatted() (string, []MessageEntity) {
+	if m.Text == "" && m.Caption != "" {
+		return m.Caption, m.CaptionEntities
+	}
+	return m.Text, m.Entities
+}
+
+// Markup of one parse mode.
+type entityRenderer interface {
+	open(entity MessageEntity) string
+	close(entity MessageEntity) string
+	escape(s string, code bool) string
+}
+
+// Render the text with the markup of its entities. Entities are expected to
+// be nested, partially overlapping ones are closed and opened again around
+// the end of the inner one.
+func renderEntities(text string, entities []MessageEntity, r entityRenderer) string {
+	sorted := make([]MessageEntity, 0, len(entities))
+	for _, entity := range entities {
+		if entity.Length > 0 {
+			sorted = append(sorted, entity)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Offset != sorted[j].Offset {
+			return sorted[i].Offset < sorted[j].Offset
+		}
+		return sorted[i].Length > sorted[j].Length
+	})
+
+	var b, segment strings.Builder
+	var stack []MessageEntity
+	// Markup written last, unless text followed it.
+	last := ""
+	markup := func(s string) {
+		// In MarkdownV2, an italic marker followed by an underline one reads
+		// as an underline marker followed by an italic one: a carriage
+		// return, which Telegram ignores, separates them.
+		if last == "_" && strings.HasPrefix(s, "_") {
+			b.WriteString("\r")
+		}
+		b.WriteString(s)
+		if s != "" {
+			last = s
+		}
+	}
+	inCode := func() bool {
+		for _, entity := range stack {
+			if entity.Type == EntityCode || entity.Type == EntityPre {
+				return true
+			}
+		}
+		return false
+	}
+	flush := func() {
+		if segment.Len() > 0 {
+			b.WriteString(r.escape(segment.String(), inCode()))
+			segment.Reset()
+			last = ""
+		}
+	}
+	// Close the entities ending at pos, reopening the ones above them in the
+	// stack which go on.
+	closeEnded := func(pos int) {
+		for {
+			i := len(stack) - 1
+			for i >= 0 && stack[i].Offset+stack[i].Length > pos {
+				i--
+			}
+			if i < 0 {
+				return
+			}
+			flush()
+			reopen := append([]MessageEntity(nil), stack[i+1:]...)
+			for j := len(stack) - 1; j >= i; j-- {
+				markup(r.close(stack[j]))
+			}
+			stack = append(stack[:i], reopen...)
+			for _, entity := range reopen {
+				markup(r.open(entity))
+			}
+		}
+	}
+
+	next, pos := 0, 0
+	for _, c := range text {
+		closeEnded(pos)
+		for next < len(sorted) && sorted[next].Offset <= pos {
+			flush()
+			markup(r.open(sorted[next]))
+			stack = append(stack, sorted[next])
+			next++
+		}
+		segment.WriteRune(c)
+		pos += utf16.RuneLen(c)
+	}
+	flush()
+	for i := len(stack) - 1; i >= 0; i-- {
+		markup(r.close(stack[i]))
+	}
+	return b.String()
+}
+
+type htmlRenderer struct{}
+
+func (htmlRenderer) open(entity MessageEntity) string {
+	switch entity.Type {
+	case EntityBold:
+		return "<b>"
+	case EntityItalic:
+		return "<i>"
+	case EntityUnderline:
+		return "<u>"
+	case EntityStrike:
+		return "<s>"
+	case EntitySpoiler:
+		return "<tg-spoiler>"
+	case EntityBlockquote:
+		return "<blockquote>"
+	case EntityCode:
+		return "<code>"
+	case EntityPre:
+		if entity.Language != "" {
+			return `<pre><code class="language-` +
+				format.EscapeHTML(entity.Language) + `">`
+		}
+		return "<pre>"
+	case EntityTextLink:
+		return `<a href="` + format.EscapeHTML(entity.URL) + `">`
+	case EntityTextMention:
+		if entity.User != nil {
+			return `<a href="tg://user?id=` + strconv.Itoa(entity.User.ID) + `">`
+		}
+	}
+	return ""
+}
+
+func (htmlRenderer) close(entity MessageEntity) string {
+	switch entity.Type {
+	case EntityBold:
+		return "</b>"
+	case EntityItalic:
+		return "</i>"
+	case EntityUnderline:
+		return "</u>"
+	case EntityStrike:
+		return "</s>"
+	case EntitySpoiler:
+		return "</tg-spoiler>"
+	case EntityBlockquote:
+		return "</blockquote>"
+	case EntityCode:
+		return "</code>"
+	case EntityPre:
+		if entity.Language != "" {
+			return "</code></pre>"
+		}
+		return "</pre>"
+	case EntityTextLink:
+		return "</a>"
+	case EntityTextMention:
+		if entity.User != nil {
+			return "</a>"
+		}
+	}
+	return ""
+}
+
+func (htmlRenderer) escape(s string, code bool) string {
+	return format.EscapeHTML(s)
+}
+
+type markdownV2Renderer struct{}
+
+func (markdownV2Renderer) open(entity MessageEntity) string {
+	switch entity.Type {
+	case EntityBold:
+		return "*"
+	case EntityItalic:
+		return "_"
+	case EntityUnderline:
+		return "__"
+	case EntityStrike:
+		return "~"
+	case EntitySpoiler:
+		return "||"
+	case EntityCode:
+		return "`"
+	case EntityPre:
+		return "```" + format.EscapeMarkdownV2Code(entity.Language) + "\n"
+	case EntityTextLink:
+		return "["
+	case EntityTextMention:
+		if entity.User != nil {
+			return "["
+		}
+	}
+	return ""
+}
+
+func (markdownV2Renderer) close(entity MessageEntity) string {
+	switch entity.Type {
+	case EntityBold:
+		return "*"
+	case EntityItalic:
+		return "_"
+	case EntityUnderline:
+		return "__"
+	case EntityStrike:
+		return "~"
+	case EntitySpoiler:
+		return "||"
+	case EntityCode:
+		return "`"
+	case EntityPre:
+		return "\n```"
+	case EntityTextLink:
+		return "](" + format.EscapeMarkdownV2URL(entity.URL) + ")"
+	case EntityTextMention:
+		if entity.User != nil {
+			return "](tg://user?id=" + strconv.Itoa(entity.User.ID) + ")"
+		}
+	}
+	return ""
+}
+
+func (markdownV2Renderer) escape(s string, code bool) string {
+	if code {
+		return format.EscapeMarkdownV2Code(s)
+	}
+	return format.EscapeMarkdownV2(s)
+}
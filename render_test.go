@@ -0,0 +1,108 @@
+package bot
+
+import "testing"
+
+func TestRenderEntities(t *testing.T) {
+	mention := &User{ID: 42, FirstName: "Bob"}
+	tests := []struct {
+		name     string
+		text     string
+		entities []MessageEntity
+		html     string
+		markdown string
+	}{
+		{
+			name:     "plain",
+			text:     "a < b & c_d*",
+			html:     "a &lt; b &amp; c_d*",
+			markdown: `a < b & c\_d\*`,
+		},
+		{
+			name:     "nested",
+			text:     "bold italic",
+			entities: []MessageEntity{{Type: EntityBold, Offset: 0, Length: 11}, {Type: EntityItalic, Offset: 5, Length: 6}},
+			html:     "<b>bold <i>italic</i></b>",
+			markdown: "*bold _italic_*",
+		},
+		{
+			name: "overlapping",
+			text: "abcdef",
+			entities: []MessageEntity{
+				{Type: EntityBold, Offset: 0, Length: 4},
+				{Type: EntityStrike, Offset: 2, Length: 4},
+			},
+			html:     "<b>ab<s>cd</s></b><s>ef</s>",
+			markdown: "*ab~cd~*~ef~",
+		},
+		{
+			name: "italic in underline",
+			text: "ab",
+			entities: []MessageEntity{
+				{Type: EntityUnderline, Offset: 0, Length: 2},
+				{Type: EntityItalic, Offset: 0, Length: 2},
+			},
+			html:     "<u><i>ab</i></u>",
+			markdown: "___ab_\r__",
+		},
+		{
+			name: "underline in italic",
+			text: "ab",
+			entities: []MessageEntity{
+				{Type: EntityItalic, Offset: 0, Length: 2},
+				{Type: EntityUnderline, Offset: 1, Length: 1},
+			},
+			html:     "<i>a<u>b</u></i>",
+			markdown: "_a__b___",
+		},
+		{
+			name: "adjacent italics",
+			text: "ab",
+			entities: []MessageEntity{
+				{Type: EntityItalic, Offset: 0, Length: 1},
+				{Type: EntityItalic, Offset: 1, Length: 1},
+			},
+			html:     "<i>a</i><i>b</i>",
+			markdown: "_a_\r_b_",
+		},
+		{
+			name:     "code",
+			text:     "x<y `*` \\",
+			entities: []MessageEntity{{Type: EntityCode, Offset: 0, Length: 9}},
+			html:     "<code>x&lt;y `*` \\</code>",
+			markdown: "`x<y \\`*\\` \\\\`",
+		},
+		{
+			name:     "pre",
+			text:     "if a {}",
+			entities: []MessageEntity{{Type: EntityPre, Offset: 0, Length: 7, Language: "go"}},
+			html:     `<pre><code class="language-go">if a {}</code></pre>`,
+			markdown: "```go\nif a {}\n```",
+		},
+		{
+			name: "links",
+			text: "site 😀 Bob",
+			entities: []MessageEntity{
+				{Type: EntityTextLink, Offset: 0, Length: 4, URL: `http://x/(a)?b="c"&d`},
+				{Type: EntityTextMention, Offset: 8, Length: 3, User: mention},
+			},
+			html:     `<a href="http://x/(a)?b=&quot;c&quot;&amp;d">site</a> 😀 <a href="tg://user?id=42">Bob</a>`,
+			markdown: `[site](http://x/(a\)?b="c"&d) 😀 [Bob](tg://user?id=42)`,
+		},
+		{
+			name:     "spoiler after emoji",
+			text:     "😀 secret.",
+			entities: []MessageEntity{{Type: EntitySpoiler, Offset: 3, Length: 7}},
+			html:     "😀 <tg-spoiler>secret.</tg-spoiler>",
+			markdown: `😀 ||secret\.||`,
+		},
+	}
+	for _, test := range tests {
+		m := &Message{Text: test.text, Entities: test.entities}
+		if got := m.HTML(); got != test.html {
+			t.Errorf("%s: got HTML %q, want %q", test.name, got, test.html)
+		}
+		if got := m.MarkdownV2(); got != test.markdown {
+			t.Errorf("%s: got MarkdownV2 %q, want %q", test.name, got, test.markdown)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package bot
+
+import "sort"
+
+// Kinds of updates, named after the optional fields of Update.
+const (
+	UpdateMessage            = "message"
+	UpdateEditedMessage      = "edited_message"
+	UpdateChannelPost        = "channel_post"
+	UpdateEditedChannelPost  = "edited_channel_post"
+	UpdateMessageReaction    = "message_reaction"
+	UpdateInlineQuery        = "inline_query"
+	UpdateChosenInlineResult = "chosen_inline_result"
+	UpdateCallbackQuery      = "callback_query"
+	UpdateShippingQuery      = "shipping_query"
+	UpdatePreCheckoutQuery   = "pre_checkout_query"
+	UpdatePoll               = "poll"
+	UpdatePollAnswer         = "poll_answer"
+	UpdateMyChatMember       = "my_chat_member"
+	UpdateChatMember         = "chat_member"
+	UpdateChatJoinRequest    = "chat_join_request"
+)
+
+// Every kind of update, in the order of the fields of Update.
+var allUpdates = []string{
+	UpdateMessage,
+	UpdateEditedMessage,
+	UpdateChannelPost,
+	UpdateEditedChannelPost,
+	UpdateMessageReaction,
+	UpdateInlineQuery,
+	UpdateChosenInlineResult,
+	UpdateCallbackQuery,
+	UpdateShippingQuery,
+	UpdatePreCheckoutQuery,
+	UpdatePoll,
+	UpdatePollAnswer,
+	UpdateMyChatMember,
+	UpdateChatMember,
+	UpdateChatJoinRequest,
+}
+
+// Kinds of updates which are only delivered when explicitly listed in
+// allowed_updates.
+var optInUpdates = map[string]bool{
+	UpdateMessageReaction: true,
+	UpdateChatMember:      true,
+}
+
+// Kind returns the kind of the update, i.e. the name of its only present
+// optional field, or an empty string if the update is of an unknown kind.
+func (update *Update) Kind() string {
+	switch {
+	case update.Message != nil:
+		return UpdateMessage
+	case update.EditedMessage != nil:
+		return UpdateEditedMessage
+	case update.ChannelPost != nil:
+		return UpdateChannelPost
+	case update.EditedChannelPost != nil:
+		return UpdateEditedChannelPost
+	case update.MessageReaction != nil:
+		return UpdateMessageReaction
+	case update.InlineQuery != nil:
+		return UpdateInlineQuery
+	case update.ChosenInlineResult != nil:
+		return UpdateChosenInlineResult
+	case update.CallbackQuery != nil:
+		return UpdateCallbackQuery
+	case update.ShippingQuery != nil:
+		return UpdateShippingQuery
+	case update.PreCheckoutQuery != nil:
+		return UpdatePreCheckoutQuery
+	case update.Poll != nil:
+		return UpdatePoll
+	case update.PollAnswer != nil:
+		return UpdatePollAnswer
+	case update.MyChatMember != nil:
+		return UpdateMyChatMember
+	case update.ChatMember != nil:
+		return UpdateChatMember
+	case update.ChatJoinRequest != nil:
+		return UpdateChatJoinRequest
+	}
+	return ""
+}
+
+// On appends a handler to the handlers chain which is only called for the
+// given kind of updates. The kinds registered with On are used to compute the
+// allowed updates of the bot.
+func (e *Bot) On(kind string, handler HandlerFunc) {
+	if e.routes == nil {
+		e.routes = make(map[string]bool)
+	}
+	e.routes[kind] = true
+	e.handlers = append(e.handlers, func(c *Context) error {
+		if c.Update.Kind() != kind {
+			return nil
+		}
+		return handler(c)
+	})
+}
+
+// SetAllowedUpdates sets the kinds of updates the bot receives, overriding the
+// ones computed from the registered handlers.
+func (e *Bot) SetAllowedUpdates(kinds ...string) {
+	e.allowedUpdates = kinds
+}
+
+// AllowedUpdates returns the kinds of updates the bot asks for. Unless set
+// explicitly, they are computed from the handlers: only the kinds registered
+// with On if there is no catch-all handler, otherwise the kinds Telegram sends
+// by default plus the opt-in kinds registered with On. The result is never
+// nil: an empty list asks Telegram for the kinds it sends by default, since
+// leaving the list out would keep the one of the previous run.
+func (e *Bot) AllowedUpdates() []string {
+	if e.allowedUpdates != nil {
+		return e.allowedUpdates
+	}
+	kinds := []string{}
+	if len(e.routes) == 0 {
+		return kinds
+	}
+	if !e.catchAll {
+		for kind := range e.routes {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		return kinds
+	}
+	optIn := false
+	for kind := range e.routes {
+		if optInUpdates[kind] {
+			optIn = true
+		}
+	}
+	if !optIn {
+		return kinds
+	}
+	for _, kind := range allUpdates {
+		if !optInUpdates[kind] || e.routes[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
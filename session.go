@@ -0,0 +1,322 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// Session holds data kept between updates, by default per user and chat.
+	// Values are stored as JSON so that every store behaves the same.
+	Session struct {
+		// Key of the session in the store.
+		Key string `json:"key"`
+		// Version of the session, incremented by every save. A session is
+		// only saved if the stored version did not change since it was
+		// loaded.
+		Version int64 `json:"version"`
+		// Values of the session by name.
+		Values map[string]json.RawMessage `json:"values"`
+		// Time of the last save.
+		Updated time.Time `json:"updated"`
+
+		// Names of the values set or deleted since the session was loaded.
+		changed map[string]bool
+	}
+
+	// SessionStore loads and saves sessions.
+	SessionStore interface {
+		// LoadSession returns the session stored with the key, or a new
+		// session with version 0 if there is none.
+		LoadSession(key string) (*Session, error)
+		// SaveSession stores the session and increments its version. Returns
+		// ErrSessionConflict if the stored version is not the one of the
+		// session.
+		SaveSession(session *Session) error
+	}
+
+	// SessionKeyFunc returns the key of the session of an update, or an
+	// empty string if the update has no session.
+	SessionKeyFunc func(update *Update) string
+
+	// MemorySessionStore keeps sessions in memory, dropping the ones which
+	// have not been saved for a while.
+	MemorySessionStore struct {
+		mutex    sync.Mutex
+		ttl      time.Duration
+		sessions map[string]*Session
+		// Time the expired sessions were last dropped.
+		swept time.Time
+	}
+
+	// FileSessionStore keeps sessions as JSON files in a directory.
+	FileSessionStore struct {
+		mutex sync.Mutex
+		dir   string
+	}
+)
+
+// ErrSessionConflict is returned when a session was saved by someone else
+// since it was loaded.
+var ErrSessionConflict = errors.New("session modified concurrently")
+
+// Number of times a conflicting save of a session is retried.
+const sessionRetries = 3
+
+// SetSessionStore enables sessions. The session of each update is loaded
+// before the handlers chain runs and saved after it, if modified. If the
+// session was saved concurrently in between, the values set or deleted by
+// the handlers are applied again to the stored session, the last write of a
+// value winning; use Context.UpdateSession to detect conflicts instead.
+func (e *Bot) SetSessionStore(store SessionStore) {
+	e.sessionStore = store
+}
+
+// SetSessionKey replaces the default session key, which is made of the chat
+// and user of the update.
+func (e *Bot) SetSessionKey(key SessionKeyFunc) {
+	e.sessionKey = key
+}
+
+func defaultSessionKey(update *Update) string {
+	chat, user := updateChat(update), updateUser(update)
+	switch {
+	case chat != nil && user != nil:
+		return strconv.FormatInt(chat.ID, 10) + ":" + strconv.Itoa(user.ID)
+	case chat != nil:
+		return strconv.FormatInt(chat.ID, 10) + ":"
+	case user != nil:
+		return ":" + strconv.Itoa(user.ID)
+	}
+	return ""
+}
+
+// Load the session of an update, nil if it has none.
+func (e *Bot) loadSession(update *Update) (*Session, error) {
+	if e.sessionStore == nil {
+		return nil, nil
+	}
+	key := e.sessionKey
+	if key == nil {
+		key = defaultSessionKey
+	}
+	k := key(update)
+	if k == "" {
+		return nil, nil
+	}
+	return e.sessionStore.LoadSession(k)
+}
+
+// Save a session if modified. On conflict, its changes are applied to the
+// stored session, which is saved in turn.
+func (e *Bot) saveSession(session *Session) error {
+	if session == nil || !session.modified() {
+		return nil
+	}
+	for attempt := 0; ; attempt++ {
+		err := e.sessionStore.SaveSession(session)
+		if err != ErrSessionConflict || attempt == sessionRetries {
+			return err
+		}
+		stored, err := e.sessionStore.LoadSession(session.Key)
+		if err != nil {
+			return err
+		}
+		for name := range session.changed {
+			if value, ok := session.Values[name]; ok {
+				stored.setRaw(name, value)
+			} else {
+				stored.Delete(name)
+			}
+		}
+		session = stored
+	}
+}
+
+// Get decodes the value with the name into v. Returns false if there is no
+// such value.
+func (s *Session) Get(name string, v interface{}) (bool, error) {
+	data, ok := s.Values[name]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// Set encodes v as the value with the name.
+func (s *Session) Set(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.setRaw(name, data)
+	return nil
+}
+
+// Delete deletes the value with the name.
+func (s *Session) Delete(name string) {
+	if _, ok := s.Values[name]; ok {
+		delete(s.Values, name)
+		s.change(name)
+	}
+}
+
+func (s *Session) setRaw(name string, data json.RawMessage) {
+	if s.Values == nil {
+		s.Values = make(map[string]json.RawMessage)
+	}
+	s.Values[name] = data
+	s.change(name)
+}
+
+func (s *Session) change(name string) {
+	if s.changed == nil {
+		s.changed = make(map[string]bool)
+	}
+	s.changed[name] = true
+}
+
+// Whether values were set or deleted since the session was loaded or saved.
+func (s *Session) modified() bool {
+	return len(s.changed) > 0
+}
+
+func (s *Session) clone() *Session {
+	clone := *s
+	clone.Values = make(map[string]json.RawMessage, len(s.Values))
+	for k, v := range s.Values {
+		clone.Values[k] = v
+	}
+	clone.changed = nil
+	return &clone
+}
+
+// NewMemorySessionStore returns a store dropping the sessions not saved for
+// ttl. Zero means sessions never expire. Expired sessions are no longer
+// loaded, and are removed from memory once per ttl.
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]*Session),
+		swept:    time.Now(),
+	}
+}
+
+func (s *MemorySessionStore) LoadSession(key string) (*Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session, ok := s.sessions[key]
+	if !ok || s.expired(session) {
+		return &Session{Key: key}, nil
+	}
+	return session.clone(), nil
+}
+
+func (s *MemorySessionStore) SaveSession(session *Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var version int64
+	if stored, ok := s.sessions[session.Key]; ok && !s.expired(stored) {
+		version = stored.Version
+	}
+	if version != session.Version {
+		return ErrSessionConflict
+	}
+	session.Version++
+	session.Updated = time.Now()
+	session.changed = nil
+	s.sessions[session.Key] = session.clone()
+	if s.ttl > 0 && time.Since(s.swept) >= s.ttl {
+		for key, stored := range s.sessions {
+			if s.expired(stored) {
+				delete(s.sessions, key)
+			}
+		}
+		s.swept = time.Now()
+	}
+	return nil
+}
+
+func (s *MemorySessionStore) expired(session *Session) bool {
+	return s.ttl > 0 && time.Since(session.Updated) > s.ttl
+}
+
+// NewFileSessionStore returns a store keeping one JSON file per session in
+// dir, which is created if needed.
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+func (s *FileSessionStore) LoadSession(key string) (*Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.read(key)
+}
+
+func (s *FileSessionStore) SaveSession(session *Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored, err := s.read(session.Key)
+	if err != nil {
+		return err
+	}
+	if stored.Version != session.Version {
+		return ErrSessionConflict
+	}
+	session.Version++
+	session.Updated = time.Now()
+	data, err := json.Marshal(session)
+	if err != nil {
+		session.Version--
+		return err
+	}
+	path := s.path(session.Key)
+	tmp, err := ioutil.TempFile(s.dir, filepath.Base(path)+".*")
+	if err != nil {
+		session.Version--
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		session.Version--
+		return err
+	}
+	session.changed = nil
+	return nil
+}
+
+func (s *FileSessionStore) read(key string) (*Session, error) {
+	data, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return &Session{Key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *FileSessionStore) path(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key)+".json")
+}
@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStoreSweep(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour)
+	for _, key := range []string{"a", "b"} {
+		if err := store.SaveSession(&Session{Key: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.sessions["a"].Updated = time.Now().Add(-2 * time.Hour)
+
+	// Expired sessions are no longer loaded, but only dropped once per ttl.
+	if session, _ := store.LoadSession("a"); session.Version != 0 {
+		t.Errorf("got version %d of an expired session, want a new session", session.Version)
+	}
+	if err := store.SaveSession(&Session{Key: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.sessions) != 3 {
+		t.Fatalf("got %d sessions, want 3 before the sweep", len(store.sessions))
+	}
+	store.swept = time.Now().Add(-2 * time.Hour)
+	if err := store.SaveSession(&Session{Key: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.sessions["a"]; ok || len(store.sessions) != 3 {
+		t.Errorf("got %d sessions, want the expired one dropped", len(store.sessions))
+	}
+}
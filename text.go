@@ -0,0 +1,112 @@
+package bot
+
+import "strings"
+
+// TextBuilder builds a plain text along with the entities formatting it, to
+// be sent with the Entities or CaptionEntities of a request instead of a
+// parse mode. No escaping is involved.
+type TextBuilder struct {
+	buf      strings.Builder
+	length   int
+	entities []MessageEntity
+}
+
+func NewTextBuilder() *TextBuilder {
+	return &TextBuilder{}
+}
+
+// String returns the built text.
+func (b *TextBuilder) String() string {
+	return b.buf.String()
+}
+
+// Entities returns the entities of the built text, ordered by offset.
+func (b *TextBuilder) Entities() []MessageEntity {
+	return b.entities
+}
+
+// Text appends plain text.
+func (b *TextBuilder) Text(s string) *TextBuilder {
+	b.buf.WriteString(s)
+	b.length += UTF16Len(s)
+	return b
+}
+
+// Entity appends text covered by an entity of the given type.
+func (b *TextBuilder) Entity(entityType, s string) *TextBuilder {
+	return b.add(MessageEntity{Type: entityType}, s)
+}
+
+// Nest appends the text built by build, covered by the entity, whose offset
+// and length are computed. The text built by build may have entities of its
+// own.
+func (b *TextBuilder) Nest(entity MessageEntity, build func(b *TextBuilder)) *TextBuilder {
+	i, offset := len(b.entities), b.length
+	build(b)
+	entity.Offset = offset
+	entity.Length = b.length - offset
+	if entity.Length == 0 {
+		return b
+	}
+	b.entities = append(b.entities, MessageEntity{})
+	copy(b.entities[i+1:], b.entities[i:])
+	b.entities[i] = entity
+	return b
+}
+
+// Bold appends bold text.
+func (b *TextBuilder) Bold(s string) *TextBuilder {
+	return b.Entity(EntityBold, s)
+}
+
+// Italic appends italic text.
+func (b *TextBuilder) Italic(s string) *TextBuilder {
+	return b.Entity(EntityItalic, s)
+}
+
+// Underline appends underlined text.
+func (b *TextBuilder) Underline(s string) *TextBuilder {
+	return b.Entity(EntityUnderline, s)
+}
+
+// Strike appends strikethrough text.
+func (b *TextBuilder) Strike(s string) *TextBuilder {
+	return b.Entity(EntityStrike, s)
+}
+
+// Spoiler appends text hidden behind a spoiler.
+func (b *TextBuilder) Spoiler(s string) *TextBuilder {
+	return b.Entity(EntitySpoiler, s)
+}
+
+// Code appends inline fixed-width code.
+func (b *TextBuilder) Code(s string) *TextBuilder {
+	return b.Entity(EntityCode, s)
+}
+
+// Pre appends a pre-formatted fixed-width code block, highlighted for the
+// programming language if not empty.
+func (b *TextBuilder) Pre(s, language string) *TextBuilder {
+	return b.add(MessageEntity{Type: EntityPre, Language: language}, s)
+}
+
+// Link appends text linking to the URL.
+func (b *TextBuilder) Link(s, url string) *TextBuilder {
+	return b.add(MessageEntity{Type: EntityTextLink, URL: url}, s)
+}
+
+// Mention appends text mentioning the user, which works even for users
+// without a username.
+func (b *TextBuilder) Mention(s string, user *User) *TextBuilder {
+	return b.add(MessageEntity{Type: EntityTextMention, User: user}, s)
+}
+
+func (b *TextBuilder) add(entity MessageEntity, s string) *TextBuilder {
+	entity.Offset = b.length
+	entity.Length = UTF16Len(s)
+	b.Text(s)
+	if entity.Length > 0 {
+		b.entities = append(b.entities, entity)
+	}
+	return b
+}
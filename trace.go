@@ -0,0 +1,92 @@
+package bot
+
+import "context"
+
+type (
+	// Tracer starts the spans tracing the resolution of updates and the
+	// calls to the Bot API. See the otel package for an OpenTelemetry
+	// adapter.
+	Tracer interface {
+		// StartSpan starts a span named name, child of the span carried by
+		// ctx if any, and returns a context carrying the new span.
+		StartSpan(ctx context.Context, name string) (context.Context, Span)
+	}
+
+	// Span is an operation traced by a Tracer.
+	Span interface {
+		// SetAttribute sets an attribute of the span. The value is a string,
+		// an int, an int64 or a bool.
+		SetAttribute(key string, value interface{})
+		// RecordError marks the span as failed with err.
+		RecordError(err error)
+		// End ends the span.
+		End()
+	}
+)
+
+// Names of the spans started by the bot. The span of a call to the Bot API is
+// named after the prefix followed by the method.
+const (
+	SpanUpdate     = "telegram.update"
+	SpanCallPrefix = "telegram.call "
+)
+
+// SetTracer makes the bot start a span for each update it resolves, with a
+// child span for each call to the Bot API made by the handlers through the
+// Bot of their Context.
+func (e *Bot) SetTracer(tracer Tracer) {
+	e.tracer = tracer
+}
+
+// WithContext returns a shallow copy of the bot calling the Bot API with ctx,
+// which cancels the calls and carries the parent of their spans. The copy
+// shares its handlers, stores and settings with the bot.
+func (e *Bot) WithContext(ctx context.Context) *Bot {
+	clone := *e
+	clone.ctx = ctx
+	return &clone
+}
+
+// Context the bot calls the Bot API with.
+func (e *Bot) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// Start the span of an update, if the bot has a tracer.
+func (e *Bot) startUpdateSpan(ctx context.Context, update *Update) (context.Context, Span) {
+	if e.tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := e.tracer.StartSpan(ctx, SpanUpdate)
+	span.SetAttribute("telegram.update_id", update.UpdateID)
+	span.SetAttribute("telegram.update_kind", update.Kind())
+	if chat := updateChat(update); chat != nil {
+		span.SetAttribute("telegram.chat_id", chat.ID)
+	}
+	return ctx, span
+}
+
+// Start the span of a call to the Bot API, if the bot has a tracer.
+func (e *Bot) startCallSpan(method string) (context.Context, Span) {
+	ctx := e.context()
+	if e.tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := e.tracer.StartSpan(ctx, SpanCallPrefix+method)
+	span.SetAttribute("telegram.method", method)
+	return ctx, span
+}
+
+// End a span, recording err if not nil. span may be nil.
+func endSpan(span Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,344 @@
+package bot
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+)
+
+type (
+	// WebhookConfig configures how the bot receives updates in webhook mode.
+	WebhookConfig struct {
+		// Address to listen on. Defaults to the port of the webhook URL, or
+		// ":443".
+		Addr string
+		// Secret token Telegram sends in the X-Telegram-Bot-Api-Secret-Token
+		// header of every request. Requests without it are rejected.
+		SecretToken string
+		// Files of the certificate and private key to serve TLS with. When
+		// both are empty and SelfSigned is false, the bot serves plain HTTP,
+		// e.g. behind a reverse proxy terminating TLS.
+		CertFile string
+		KeyFile  string
+		// Generate a self-signed certificate, upload it to Telegram and serve
+		// TLS with it. If CertFile and KeyFile are set, the certificate is
+		// kept in them and reused across restarts while still valid.
+		SelfSigned bool
+		// Host name or IP address the self-signed certificate is issued for.
+		// Defaults to the host of the webhook URL.
+		Host string
+		// Validity of self-signed certificates. Defaults to a year.
+		Validity time.Duration
+		// How long before its expiry a self-signed certificate is replaced
+		// by a new one. Defaults to a tenth of the validity, which is also
+		// used if RenewBefore is not shorter than the validity.
+		RenewBefore time.Duration
+		// Maximum number of simultaneous connections Telegram opens, 1-100.
+		MaxConnections int
+		// Drop the updates pending when the webhook is set.
+		DropPendingUpdates bool
+	}
+
+	// Certificate served by the webhook server, replaced on rotation.
+	webhookCertificate struct {
+		mutex sync.RWMutex
+		cert  *tls.Certificate
+		// Host name or IP address the certificate is issued for.
+		host string
+	}
+)
+
+const (
+	defaultCertificateValidity = 365 * 24 * time.Hour
+	// Maximum size of the body of the requests posted by Telegram.
+	maxWebhookBody = 1 << 20
+	// Delay before retrying a failed certificate rotation.
+	rotationRetry = time.Hour
+	// Timeouts of the webhook server. There is no write timeout, as
+	// answering includes resolving the update.
+	webhookReadHeaderTimeout = 10 * time.Second
+	webhookReadTimeout       = 30 * time.Second
+	webhookIdleTimeout       = 2 * time.Minute
+)
+
+// SetWebhookConfig sets how the bot receives updates in webhook mode.
+func (e *Bot) SetWebhookConfig(config *WebhookConfig) {
+	e.webhook = config
+}
+
+// Run the bot in webhook mode: set the webhook to url and serve the updates
+// Telegram posts to it. Each update is resolved before answering Telegram,
+// which sends it again if the bot fails to answer. Runs until the server
+// fails; the webhook is only set once the server listens.
+func (e *Bot) RunWebhook(url string) error {
+	e.Logger().Info("running in webhook mode", slog.String("url", e.redactString(url)))
+	config := e.webhook
+	if config == nil {
+		config = &WebhookConfig{}
+	}
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return err
+	}
+	addr := config.Addr
+	if addr == "" {
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		addr = ":" + port
+	}
+	req := &SetWebhookRequest{
+		URL:                url,
+		MaxConnections:     config.MaxConnections,
+		DropPendingUpdates: config.DropPendingUpdates,
+		SecretToken:        config.SecretToken,
+	}
+	server := &http.Server{
+		Handler:           e.webhookHandler(u.Path, config.SecretToken),
+		ReadHeaderTimeout: webhookReadHeaderTimeout,
+		ReadTimeout:       webhookReadTimeout,
+		IdleTimeout:       webhookIdleTimeout,
+	}
+	var served *webhookCertificate
+	if config.SelfSigned {
+		host := config.Host
+		if host == "" {
+			host = u.Hostname()
+		}
+		cert, certPEM, err := loadCertificate(config)
+		if err != nil || certificateExpiring(cert, config) {
+			cert, certPEM, err = newSelfSignedCertificate(host, config)
+			if err != nil {
+				return err
+			}
+		}
+		req.Certificate = &InputFile{Name: "cert.pem", Reader: bytes.NewReader(certPEM)}
+		served = &webhookCertificate{cert: cert, host: host}
+		server.TLSConfig = &tls.Config{GetCertificate: served.getCertificate}
+	} else if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	// Listen before setting the webhook, so that it never points to a
+	// server which failed to start.
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if err := e.SetWebhook(req); err != nil {
+		listener.Close()
+		return err
+	}
+	if server.TLSConfig == nil {
+		return server.Serve(listener)
+	}
+	if served != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go e.rotateCertificate(served, req, config, stop)
+	}
+	return server.ServeTLS(listener, "", "")
+}
+
+// Handler resolving the updates posted by Telegram to path.
+func (e *Bot) webhookHandler(path, secret string) http.Handler {
+	if path == "" {
+		path = "/"
+	}
+	dispatcher := e.newDispatcher()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		update := &Update{}
+		body := http.MaxBytesReader(w, r.Body, maxWebhookBody)
+		if err := json.NewDecoder(body).Decode(update); err != nil {
+			e.logError("decode webhook update", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if dispatcher != nil {
+			var wg sync.WaitGroup
+			wg.Add(1)
+			dispatcher.dispatch(update, wg.Done)
+			wg.Wait()
+		} else {
+			e.handle(update)
+		}
+	})
+}
+
+// Replace the self-signed certificate before it expires, uploading the new
+// one to Telegram before serving it, until stop is closed.
+func (e *Bot) rotateCertificate(served *webhookCertificate, req *SetWebhookRequest, config *WebhookConfig, stop chan struct{}) {
+	wait := time.Until(served.current().Leaf.NotAfter.Add(-renewBefore(config)))
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		wait = rotationRetry
+		next, certPEM, err := newSelfSignedCertificate(served.host, config)
+		if err != nil {
+			e.logError("generate webhook certificate", err)
+			continue
+		}
+		params := *req
+		params.DropPendingUpdates = false
+		params.Certificate = &InputFile{Name: "cert.pem", Reader: bytes.NewReader(certPEM)}
+		if err := e.SetWebhook(&params); err != nil {
+			e.logError("upload webhook certificate", err)
+			if e.metrics != nil {
+				e.metrics.ObserveRetry("setWebhook")
+			}
+			continue
+		}
+		e.Logger().Info("rotated webhook certificate", slog.Time("not_after", next.Leaf.NotAfter))
+		served.set(next)
+		wait = time.Until(next.Leaf.NotAfter.Add(-renewBefore(config)))
+	}
+}
+
+func (c *webhookCertificate) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.current(), nil
+}
+
+func (c *webhookCertificate) current() *tls.Certificate {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cert
+}
+
+func (c *webhookCertificate) set(cert *tls.Certificate) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cert = cert
+}
+
+func certificateValidity(config *WebhookConfig) time.Duration {
+	if config.Validity > 0 {
+		return config.Validity
+	}
+	return defaultCertificateValidity
+}
+
+func renewBefore(config *WebhookConfig) time.Duration {
+	if config.RenewBefore > 0 && config.RenewBefore < certificateValidity(config) {
+		return config.RenewBefore
+	}
+	return certificateValidity(config) / 10
+}
+
+func certificateExpiring(cert *tls.Certificate, config *WebhookConfig) bool {
+	return time.Until(cert.Leaf.NotAfter) < renewBefore(config)
+}
+
+// Load the self-signed certificate kept in the files of the config.
+func loadCertificate(config *WebhookConfig) (*tls.Certificate, []byte, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, nil, errors.New("no certificate files")
+	}
+	certPEM, err := ioutil.ReadFile(config.CertFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return &cert, certPEM, nil
+}
+
+// Generate a self-signed certificate for host, a host name or an IP address,
+// and keep it in the files of the config if set. Returns the certificate and
+// its PEM encoding, as uploaded to Telegram.
+func newSelfSignedCertificate(host string, config *WebhookConfig) (*tls.Certificate, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: host},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certificateValidity(config)),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.CertFile != "" && config.KeyFile != "" {
+		if err := ioutil.WriteFile(config.KeyFile, keyPEM, 0600); err != nil {
+			return nil, nil, err
+		}
+		if err := ioutil.WriteFile(config.CertFile, certPEM, 0644); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &cert, certPEM, nil
+}